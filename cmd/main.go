@@ -1,21 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"payment-service/config"
 	"payment-service/db"
+	"payment-service/internal/leader"
 	"payment-service/internal/middleware/auth"
 	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/webhook"
+	"payment-service/internal/outbox"
 	"payment-service/internal/router"
 	"payment-service/internal/scheduler"
 	"payment-service/internal/service"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish on SIGTERM.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Registered first so it runs after every other deferred Stop.
+	exitCode := 0
+	defer func() { os.Exit(exitCode) }()
+
 	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
@@ -28,34 +44,164 @@ func main() {
 	r := gin.Default()
 	r.Use(logger.Middleware())
 
-	// SOLO PARA PRUEBA
-	r.POST("/token/:id", func(c *gin.Context) {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"id": c.Param("id"),
-		})
-		tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	issuer, err := newIssuer(cfg)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	tokenService := service.NewTokenService(database, issuer, cfg.RefreshTokenTTL)
+	if cfg.BootstrapAdminUsername != "" {
+		created, err := tokenService.BootstrapAdmin(cfg.BootstrapAdminUsername, cfg.BootstrapAdminPassword)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
-			return
+			log.Fatal("Failed to create the bootstrap admin:", err)
+		}
+		if created {
+			log.Println("Created bootstrap admin login", cfg.BootstrapAdminUsername)
 		}
-		c.JSON(http.StatusOK, gin.H{"token": tokenString})
-	})
+	}
+	authMiddleware := auth.Middleware(issuer, tokenService)
+	apiKeyService := service.NewAPIKeyService(database)
+	// Resource routes also accept merchant API keys.
+	apiAuthMiddleware := auth.MiddlewareWithAPIKeys(issuer, tokenService, apiKeyService)
+
+	authGroup := r.Group("/auth")
+	{
+		router.AuthRouter(authGroup, tokenService, authMiddleware)
+	}
+
+	router.WellKnownRouter(r.Group("/.well-known"), tokenService)
+
+	// SOLO PARA PRUEBA
+	if cfg.APP_ENV == "development" {
+		router.TestTokenRouter(r.Group("/token"), tokenService)
+	}
 
 	accountGroup := r.Group("/account")
 	{
-		accountGroup.Use(auth.Middleware(cfg.JWTSecret))
-		router.AccountRouter(accountGroup, database)
+		accountGroup.Use(apiAuthMiddleware)
+		router.AccountRouter(accountGroup, database, cfg)
 	}
 
 	transferGroup := r.Group("/transfer")
 	{
-		transferGroup.Use(auth.Middleware(cfg.JWTSecret))
-		router.TransferRouter(transferGroup, database)
+		transferGroup.Use(apiAuthMiddleware)
+		router.TransferRouter(transferGroup, database, cfg)
+	}
+
+	webhookService := service.NewMerchantWebhookService(database, cfg.OutboundWebhookMaxAttempts, cfg.APP_ENV == "development")
+
+	// Every instance schedules the background jobs; only the one holding
+	// the lease runs them.
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID = leader.InstanceID()
+	}
+	elector := leader.NewElector(database, "transfer-scheduler", instanceID, cfg.LeaderLeaseTTL)
+	elector.Start()
+	defer elector.Stop()
+
+	adminGroup := r.Group("/admin")
+	{
+		adminGroup.Use(authMiddleware)
+		router.AdminRouter(adminGroup, apiKeyService, webhookService, elector)
+	}
+
+	// Provider webhooks keep their /transfer/:id/webhook path but are signed
+	// with a shared HMAC secret rather than a customer JWT.
+	webhookGroup := r.Group("/transfer")
+	{
+		webhookGroup.Use(webhook.Middleware(cfg.WebhookSecrets, cfg.WebhookTolerance))
+		router.WebhookRouter(webhookGroup, database, cfg)
 	}
 
-	transferScheduler := scheduler.NewTransferScheduler(service.NewTransferService(database))
+	transferService := service.NewTransferService(database, cfg.TransferExpiry)
+	transferScheduler := scheduler.NewTransferScheduler(
+		elector,
+		transferService,
+		service.NewIdempotencyService(database, cfg.IdempotencyKeyTTL),
+		tokenService,
+		service.NewAccountService(database),
+		service.NewScheduledTransferService(database, transferService),
+		service.NewTransferBatchService(database, cfg.TransferExpiry),
+		webhookService,
+	)
 	transferScheduler.Start()
 	defer transferScheduler.Stop()
 
-	log.Fatal(r.Run(":" + cfg.PORT))
-}
\ No newline at end of file
+	broker, err := newPublisher(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up event publisher:", err)
+	}
+	// Merchant webhooks are queued before the event goes to the broker; a
+	// broker failure retries both, and webhooks are only queued once.
+	publisher := outbox.NewInProcessPublisher()
+	publisher.Subscribe(webhookService.HandleEvent)
+	publisher.Subscribe(broker.Publish)
+	relay := outbox.NewRelay(database, publisher, cfg.OutboxRelayInterval)
+	relay.Start()
+	defer relay.Stop()
+
+	if err := serve(r, ":"+cfg.PORT); err != nil {
+		log.Println("Server failed:", err)
+		exitCode = 1
+	}
+}
+
+// serve runs the API until SIGINT or SIGTERM, then stops taking requests and
+// waits for the ones in flight, so that main's deferred Stops get to wind
+// down the background jobs and hand over the scheduler lease.
+func serve(handler http.Handler, addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	failed := make(chan error, 1)
+	go func() {
+		log.Println("Listening on", addr)
+		failed <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newIssuer loads the configured signing keys. With no key files it falls
+// back to HMAC signing with JWT_SECRET.
+func newIssuer(cfg *config.Config) (*auth.Issuer, error) {
+	keys, err := auth.LoadKeyFiles(cfg.JWTKeyFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKeyID := cfg.JWTSigningKeyID
+	if cfg.JWTSecret != "" {
+		keys = append(keys, auth.NewHMACKey("hs256", cfg.JWTSecret))
+		if signingKeyID == "" {
+			signingKeyID = "hs256"
+		}
+	}
+
+	return auth.NewIssuer(keys, signingKeyID, cfg.JWTAlgorithms, cfg.AccessTokenTTL)
+}
+
+// newPublisher returns the publisher the outbox relay sends events through.
+func newPublisher(cfg *config.Config) (outbox.Publisher, error) {
+	switch cfg.EventPublisher {
+	case "", "log":
+		return outbox.LogPublisher{}, nil
+	case "nats":
+		return outbox.NewNATSPublisher(cfg.NATSURL, cfg.NATSSubjectPrefix)
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q", cfg.EventPublisher)
+	}
+}
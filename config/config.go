@@ -2,25 +2,175 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBURL   string
-	JWTSecret string
-	PORT     string
-	APP_ENV string
+	DBURL       string
+	JWTSecret   string
+	PORT        string
+	APP_ENV     string
+	FXRatesFile string
+	FXQuoteTTL  time.Duration
+	// IdempotencyKeyTTL is how long a stored Idempotency-Key response is replayed.
+	IdempotencyKeyTTL time.Duration
+	// TransferExpiry is how long a transfer may stay pending by default.
+	TransferExpiry time.Duration
+	// WebhookSecrets are the active HMAC secrets for provider webhooks; more
+	// than one is accepted while a secret is being rotated.
+	WebhookSecrets   []string
+	WebhookTolerance time.Duration
+	// JWTKeyFiles maps a kid to the PEM file of an RSA or Ed25519 key; the
+	// HMAC JWTSecret, when set, is added as kid "hs256".
+	JWTKeyFiles     map[string]string
+	JWTSigningKeyID string
+	JWTAlgorithms   []string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// EventPublisher selects where outbox events go: "log" (default) or
+	// "nats", which publishes to NATSURL under NATSSubjectPrefix.
+	EventPublisher      string
+	NATSURL             string
+	NATSSubjectPrefix   string
+	OutboxRelayInterval time.Duration
+	// OutboundWebhookMaxAttempts is how many times a merchant webhook is
+	// tried before it is dead-lettered.
+	OutboundWebhookMaxAttempts int
+	// InstanceID names this instance in leader election; it must be unique
+	// per instance. LeaderLeaseTTL is how long a dead leader keeps the lease.
+	InstanceID     string
+	LeaderLeaseTTL time.Duration
+	// BootstrapAdminUsername and BootstrapAdminPassword create the first
+	// admin login at startup when there is none yet.
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
 }
 
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		return nil, err
 	}
+
+	fxQuoteTTL, err := durationEnv("FX_QUOTE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyKeyTTL, err := durationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	transferExpiry, err := durationEnv("TRANSFER_EXPIRY", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookTolerance, err := durationEnv("WEBHOOK_TOLERANCE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokenTTL, err := durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenTTL, err := durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxRelayInterval, err := durationEnv("OUTBOX_RELAY_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	outboundWebhookMaxAttempts, err := intEnv("OUTBOUND_WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderLeaseTTL, err := durationEnv("LEADER_LEASE_TTL", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	natsSubjectPrefix := os.Getenv("NATS_SUBJECT_PREFIX")
+	if natsSubjectPrefix == "" {
+		natsSubjectPrefix = "payments"
+	}
+
 	return &Config{
-		DBURL:    os.Getenv("DB_URL"),
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		PORT:     os.Getenv("PORT"),
-		APP_ENV:  os.Getenv("APP_ENV"),
+		DBURL:                      os.Getenv("DB_URL"),
+		JWTSecret:                  os.Getenv("JWT_SECRET"),
+		PORT:                       os.Getenv("PORT"),
+		APP_ENV:                    os.Getenv("APP_ENV"),
+		FXRatesFile:                os.Getenv("FX_RATES_FILE"),
+		FXQuoteTTL:                 fxQuoteTTL,
+		IdempotencyKeyTTL:          idempotencyKeyTTL,
+		TransferExpiry:             transferExpiry,
+		WebhookSecrets:             listEnv("WEBHOOK_SECRETS"),
+		WebhookTolerance:           webhookTolerance,
+		JWTKeyFiles:                mapEnv("JWT_KEYS"),
+		JWTSigningKeyID:            os.Getenv("JWT_SIGNING_KEY_ID"),
+		JWTAlgorithms:              listEnv("JWT_ALGORITHMS"),
+		AccessTokenTTL:             accessTokenTTL,
+		RefreshTokenTTL:            refreshTokenTTL,
+		EventPublisher:             os.Getenv("EVENT_PUBLISHER"),
+		NATSURL:                    os.Getenv("NATS_URL"),
+		NATSSubjectPrefix:          natsSubjectPrefix,
+		OutboxRelayInterval:        outboxRelayInterval,
+		OutboundWebhookMaxAttempts: outboundWebhookMaxAttempts,
+		InstanceID:                 os.Getenv("INSTANCE_ID"),
+		LeaderLeaseTTL:             leaderLeaseTTL,
+		BootstrapAdminUsername:     os.Getenv("BOOTSTRAP_ADMIN_USERNAME"),
+		BootstrapAdminPassword:     os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"),
 	}, nil
-}
\ No newline at end of file
+}
+
+// durationEnv reads a Go duration (e.g. "30s", "5m") from the environment,
+// falling back to def when the variable is unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// intEnv reads an integer from the environment, falling back to def when the
+// variable is unset.
+func intEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// listEnv reads a comma-separated list from the environment, skipping blanks.
+func listEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// mapEnv reads comma-separated key=value pairs from the environment.
+func mapEnv(key string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range listEnv(key) {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return values
+}
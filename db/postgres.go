@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/driver/postgres"
 
+	"payment-service/internal/ledger"
 	"payment-service/internal/model"
 )
 
@@ -19,9 +20,91 @@ func Connect(strConn string) *gorm.DB {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	if err = db.AutoMigrate(&model.Transfer{}, &model.Account{}); err != nil {
+	if err = migrateMoneyColumns(db); err != nil {
+		log.Fatal("Failed to migrate money columns:", err)
+	}
+
+	if err = db.AutoMigrate(&model.Transfer{}, &model.Account{}, &model.FXRate{}, &model.FXQuote{}, &model.LedgerEntry{}, &model.IdempotencyKey{}, &model.TransferStatusHistory{},
+		&model.AccountCredential{}, &model.RefreshToken{}, &model.RevokedToken{}, &model.APIKey{}, &model.BalanceSnapshot{},
+		&model.ScheduledTransfer{}, &model.ScheduledTransferExecution{}, &model.TransferBatch{}, &model.TransferBatchItem{}, &model.OutboxEvent{},
+		&model.WebhookEndpoint{}, &model.WebhookDelivery{}, &model.WebhookDeliveryAttempt{}, &model.LeaderLease{}); err != nil {
 		log.Fatal("Failed to auto-migrate database:", err)
 	}
 
+	if err = createTransferListIndexes(db); err != nil {
+		log.Fatal("Failed to create transfer indexes:", err)
+	}
+
+	if err = backfillTransferDestinations(db); err != nil {
+		log.Fatal("Failed to backfill transfer destinations:", err)
+	}
+
+	if err = ledger.BackfillOpeningBalances(db); err != nil {
+		log.Fatal("Failed to backfill opening balances:", err)
+	}
+
 	return db
-}
\ No newline at end of file
+}
+
+// migrateMoneyColumns converts the legacy float balance/amount columns into
+// integer minor units (cents) before AutoMigrate changes their type, which
+// would otherwise truncate the stored values.
+func migrateMoneyColumns(db *gorm.DB) error {
+	columns := []struct {
+		model  interface{}
+		table  string
+		column string
+	}{
+		{&model.Account{}, "accounts", "balance"},
+		{&model.Transfer{}, "transfers", "amount"},
+	}
+
+	for _, c := range columns {
+		if !db.Migrator().HasColumn(c.model, c.column) {
+			continue
+		}
+		types, err := db.Migrator().ColumnTypes(c.model)
+		if err != nil {
+			return err
+		}
+		for _, t := range types {
+			if t.Name() != c.column || (t.DatabaseTypeName() != "float8" && t.DatabaseTypeName() != "numeric") {
+				continue
+			}
+			sql := "ALTER TABLE " + c.table + " ALTER COLUMN " + c.column + " TYPE bigint USING round(" + c.column + " * 100)"
+			if err := db.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createTransferListIndexes adds the indexes behind transfer listings, which
+// filter by account and page newest first by ID. They include columns from
+// gorm.Model, so they can't be declared with struct tags.
+func createTransferListIndexes(db *gorm.DB) error {
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_transfers_origin_account_id_id ON transfers (origin_account_id, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_destination_account_id_id ON transfers (destination_account_id, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_created_at_id ON transfers (created_at, id DESC)",
+	}
+	for _, sql := range indexes {
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillTransferDestinations fills the destination side of transfers created
+// before cross-currency support, all of which were same-currency moves.
+func backfillTransferDestinations(db *gorm.DB) error {
+	return db.Model(&model.Transfer{}).
+		Where("destination_currency IS NULL OR destination_currency = ''").
+		Updates(map[string]interface{}{
+			"destination_amount":   gorm.Expr("amount"),
+			"destination_currency": gorm.Expr("currency"),
+			"fx_rate":              "1",
+		}).Error
+}
@@ -0,0 +1,8 @@
+// account status constants
+package constant
+
+const (
+	AccountStatusActive = "ACTIVE"
+	AccountStatusFrozen = "FROZEN"
+	AccountStatusClosed = "CLOSED"
+)
@@ -0,0 +1,10 @@
+// domain event types published through the outbox
+package constant
+
+const (
+	EventTransferCreated   = "transfer.created"
+	EventTransferCompleted = "transfer.completed"
+	EventTransferFailed    = "transfer.failed"
+	EventTransferExpired   = "transfer.expired"
+	EventTransferRefunded  = "transfer.refunded"
+)
@@ -0,0 +1,17 @@
+// ledger posting constants
+package constant
+
+const (
+	LedgerDirectionDebit  = "DEBIT"
+	LedgerDirectionCredit = "CREDIT"
+)
+
+const (
+	// LedgerAccountCustomer postings belong to a row in the accounts table.
+	LedgerAccountCustomer = "CUSTOMER"
+	// LedgerAccountFXPosition is the house position that absorbs the two
+	// legs of a cross-currency transfer, one per currency.
+	LedgerAccountFXPosition = "FX_POSITION"
+	// LedgerAccountOpeningBalance offsets balances that predate the ledger.
+	LedgerAccountOpeningBalance = "OPENING_BALANCE"
+)
@@ -0,0 +1,19 @@
+// merchant webhook constants
+package constant
+
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliverySucceeded = "SUCCEEDED"
+	// WebhookDeliveryDead is the dead-letter state: every attempt failed and
+	// only a manual redelivery sends it again.
+	WebhookDeliveryDead = "DEAD"
+)
+
+// WebhookEventTypes are the events merchants can subscribe to.
+var WebhookEventTypes = []string{
+	EventTransferCreated,
+	EventTransferCompleted,
+	EventTransferFailed,
+	EventTransferExpired,
+	EventTransferRefunded,
+}
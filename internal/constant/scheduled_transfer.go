@@ -0,0 +1,23 @@
+// scheduled transfer constants
+package constant
+
+const (
+	ScheduleStatusActive    = "ACTIVE"
+	ScheduleStatusPaused    = "PAUSED"
+	ScheduleStatusCancelled = "CANCELLED"
+	ScheduleStatusCompleted = "COMPLETED"
+)
+
+// recurrence of a schedule; one-off schedules have none
+const (
+	ScheduleFrequencyDaily   = "DAILY"
+	ScheduleFrequencyWeekly  = "WEEKLY"
+	ScheduleFrequencyMonthly = "MONTHLY"
+)
+
+// outcome of a single run of a schedule
+const (
+	ScheduleExecutionPending   = "PENDING"
+	ScheduleExecutionSucceeded = "SUCCEEDED"
+	ScheduleExecutionFailed    = "FAILED"
+)
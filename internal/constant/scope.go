@@ -0,0 +1,32 @@
+// role and scope constants
+package constant
+
+const (
+	RoleCustomer = "customer"
+	RoleSupport  = "support"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+
+	// RoleAPIKey marks principals authenticated with an API key. It is not a
+	// login role; a key's scopes are chosen when it is created.
+	RoleAPIKey = "api_key"
+)
+
+const (
+	ScopeAccountsRead     = "accounts:read"
+	ScopeAccountsReadAny  = "accounts:read:any"
+	ScopeAccountsWriteAny = "accounts:write:any"
+	ScopeTransfersRead    = "transfers:read"
+	ScopeTransfersReadAny = "transfers:read:any"
+	ScopeTransfersWrite   = "transfers:write"
+	ScopeAdmin            = "admin"
+)
+
+// RoleScopes are the scopes granted to tokens issued for each role. The
+// admin scope satisfies any scope check.
+var RoleScopes = map[string][]string{
+	RoleCustomer: {ScopeAccountsRead, ScopeTransfersRead, ScopeTransfersWrite},
+	RoleSupport:  {ScopeAccountsRead, ScopeAccountsReadAny, ScopeTransfersRead, ScopeTransfersReadAny},
+	RoleOperator: {ScopeAccountsRead, ScopeAccountsReadAny, ScopeAccountsWriteAny, ScopeTransfersRead, ScopeTransfersReadAny},
+	RoleAdmin:    {ScopeAdmin},
+}
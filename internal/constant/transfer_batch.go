@@ -0,0 +1,22 @@
+// transfer batch constants
+package constant
+
+// how a batch treats failing items
+const (
+	BatchModeAllOrNothing = "ALL_OR_NOTHING"
+	BatchModeBestEffort   = "BEST_EFFORT"
+)
+
+const (
+	BatchStatusPending            = "PENDING"
+	BatchStatusProcessing         = "PROCESSING"
+	BatchStatusCompleted          = "COMPLETED"
+	BatchStatusPartiallyCompleted = "PARTIALLY_COMPLETED"
+	BatchStatusFailed             = "FAILED"
+)
+
+const (
+	BatchItemPending   = "PENDING"
+	BatchItemSucceeded = "SUCCEEDED"
+	BatchItemFailed    = "FAILED"
+)
@@ -2,7 +2,24 @@
 package constant
 
 const (
-	TransferStatusPending   = "PENDING"
-	TransferStatusCompleted = "COMPLETED"
-	TransferStatusFailed    = "FAILED"
-)
\ No newline at end of file
+	TransferStatusPending    = "PENDING"
+	TransferStatusProcessing = "PROCESSING"
+	TransferStatusCompleted  = "COMPLETED"
+	TransferStatusFailed     = "FAILED"
+	TransferStatusExpired    = "EXPIRED"
+	TransferStatusReversed   = "REVERSED"
+	TransferStatusCancelled  = "CANCELLED"
+)
+
+// refund status of a completed transfer that has been refunded
+const (
+	TransferRefundPartial = "PARTIALLY_REFUNDED"
+	TransferRefundFull    = "REFUNDED"
+)
+
+// actors recorded in the transfer status history
+const (
+	TransferActorWebhook   = "webhook"
+	TransferActorScheduler = "scheduler"
+	TransferActorSystem    = "system"
+)
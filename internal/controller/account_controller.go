@@ -1,15 +1,29 @@
 package controller
 
 import (
+	"bytes"
 	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
 	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/rbac"
+	"payment-service/internal/model"
 	"payment-service/internal/service"
+	"payment-service/internal/statement"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AccountController interface {
+	CreateAccount(c *gin.Context)
+	GetAccount(c *gin.Context)
+	UpdateAccount(c *gin.Context)
+	FreezeAccount(c *gin.Context)
+	UnfreezeAccount(c *gin.Context)
+	CloseAccount(c *gin.Context)
 	GetAccountBalance(c *gin.Context)
+	VerifyAccountBalance(c *gin.Context)
+	GetStatement(c *gin.Context)
 }
 
 type accountController struct {
@@ -22,6 +36,87 @@ func NewAccountController(service service.AccountService) AccountController {
 	}
 }
 
+func (ctrl *accountController) CreateAccount(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.AccountCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid account request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	account, err := ctrl.service.CreateAccount(&req, c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"result": account})
+}
+
+func (ctrl *accountController) GetAccount(c *gin.Context) {
+	log := logger.From(c)
+	accountID := c.Param("id")
+
+	if !auth.OwnsAccount(c, accountID) && !rbac.HasScope(c, constant.ScopeAccountsReadAny) {
+		log.Warnw("Forbidden account request", "account_id", accountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	account, err := ctrl.service.GetAccount(accountID, c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
+func (ctrl *accountController) UpdateAccount(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.AccountUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid account update request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	account, err := ctrl.service.UpdateAccount(c.Param("id"), &req, c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
+func (ctrl *accountController) FreezeAccount(c *gin.Context) {
+	account, err := ctrl.service.FreezeAccount(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
+func (ctrl *accountController) UnfreezeAccount(c *gin.Context) {
+	account, err := ctrl.service.UnfreezeAccount(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
+func (ctrl *accountController) CloseAccount(c *gin.Context) {
+	account, err := ctrl.service.CloseAccount(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
 func (ctrl *accountController) GetAccountBalance(c *gin.Context) {
 	log := logger.From(c)
 	accountID := c.Param("id")
@@ -31,10 +126,93 @@ func (ctrl *accountController) GetAccountBalance(c *gin.Context) {
 		return
 	}
 
-	balance, err := ctrl.service.GetAccountBalance(accountID, c)
+	if !auth.OwnsAccount(c, accountID) && !rbac.HasScope(c, constant.ScopeAccountsReadAny) {
+		log.Warnw("Forbidden balance request", "account_id", accountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var balance model.AccountBalanceResponse
+	var err *service.ServiceError
+	if asOf := c.Query("as_of"); asOf != "" {
+		balance, err = ctrl.service.GetAccountBalanceAsOf(accountID, asOf, c)
+	} else {
+		balance, err = ctrl.service.GetAccountBalance(accountID, c)
+	}
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": balance})
-}
\ No newline at end of file
+}
+
+func (ctrl *accountController) VerifyAccountBalance(c *gin.Context) {
+	log := logger.From(c)
+	accountID := c.Param("id")
+	if accountID == "" {
+		log.Errorw("Invalid account ID", "error", "Account ID is required")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	if !auth.OwnsAccount(c, accountID) && !rbac.HasScope(c, constant.ScopeAccountsReadAny) {
+		log.Warnw("Forbidden balance verification request", "account_id", accountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	verification, err := ctrl.service.VerifyAccountBalance(accountID, c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": verification})
+}
+
+// GetStatement serves the statement as JSON, or as a CSV or PDF download
+// when format is csv or pdf.
+func (ctrl *accountController) GetStatement(c *gin.Context) {
+	log := logger.From(c)
+	accountID := c.Param("id")
+
+	if !auth.OwnsAccount(c, accountID) && !rbac.HasScope(c, constant.ScopeAccountsReadAny) {
+		log.Warnw("Forbidden statement request", "account_id", accountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or pdf"})
+		return
+	}
+
+	result, err := ctrl.service.GetStatement(accountID, c.Query("from"), c.Query("to"), c)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"result": result})
+		return
+	}
+
+	var buf bytes.Buffer
+	var renderErr error
+	contentType := "text/csv"
+	if format == "csv" {
+		renderErr = statement.WriteCSV(&buf, result)
+	} else {
+		contentType = "application/pdf"
+		renderErr = statement.WritePDF(&buf, result)
+	}
+	if renderErr != nil {
+		log.Errorw("Failed to render statement", "account_id", accountID, "format", format, "error", renderErr)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render statement"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+statement.Filename(result, format)+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
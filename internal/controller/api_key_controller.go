@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController interface {
+	CreateAPIKey(c *gin.Context)
+	ListAPIKeys(c *gin.Context)
+	RevokeAPIKey(c *gin.Context)
+}
+
+type apiKeyController struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyController(service service.APIKeyService) APIKeyController {
+	return &apiKeyController{
+		service: service,
+	}
+}
+
+func (ctrl *apiKeyController) CreateAPIKey(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid API key request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	key, err := ctrl.service.CreateAPIKey(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"result": key})
+}
+
+func (ctrl *apiKeyController) ListAPIKeys(c *gin.Context) {
+	keys, err := ctrl.service.ListAPIKeys(c.Query("account_id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": keys})
+}
+
+func (ctrl *apiKeyController) RevokeAPIKey(c *gin.Context) {
+	if err := ctrl.service.RevokeAPIKey(c.Param("id"), c); err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController interface {
+	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+	Logout(c *gin.Context)
+	CreateCredentials(c *gin.Context)
+	IssueTestToken(c *gin.Context)
+	JWKS(c *gin.Context)
+}
+
+type authController struct {
+	service service.TokenService
+}
+
+func NewAuthController(service service.TokenService) AuthController {
+	return &authController{
+		service: service,
+	}
+}
+
+func (ctrl *authController) Login(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid login request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	tokens, err := ctrl.service.Login(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (ctrl *authController) Refresh(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid refresh request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	tokens, err := ctrl.service.Refresh(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (ctrl *authController) Logout(c *gin.Context) {
+	var req model.LogoutRequest
+	// The body is optional; it only carries a refresh token to revoke.
+	_ = c.ShouldBindJSON(&req)
+
+	if err := ctrl.service.Logout(&req, c); err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func (ctrl *authController) CreateCredentials(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.CredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid credentials request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	credential, err := ctrl.service.SetCredentials(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"result": credential})
+}
+
+func (ctrl *authController) IssueTestToken(c *gin.Context) {
+	tokens, err := ctrl.service.IssueTestToken(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"error": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokens.AccessToken, "expires_at": tokens.ExpiresAt})
+}
+
+func (ctrl *authController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ctrl.service.JWKS())
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FXController interface {
+	CreateQuote(c *gin.Context)
+}
+
+type fxController struct {
+	service service.FXService
+}
+
+func NewFXController(service service.FXService) FXController {
+	return &fxController{
+		service: service,
+	}
+}
+
+func (ctrl *fxController) CreateQuote(c *gin.Context) {
+	log := logger.From(c)
+	log.Infow("Creating FX quote")
+
+	var req model.FXQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid quote request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	quote, err := ctrl.service.CreateQuote(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": "Quote failed", "error": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Quote created", "quote": quote})
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/leader"
+	"payment-service/internal/middleware/logger"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LeaderController interface {
+	GetLeader(c *gin.Context)
+}
+
+type leaderController struct {
+	elector *leader.Elector
+}
+
+func NewLeaderController(elector *leader.Elector) LeaderController {
+	return &leaderController{
+		elector: elector,
+	}
+}
+
+// GetLeader reports which instance holds the scheduler lease, and whether it
+// is the one answering. leader is null until an instance has taken it.
+func (ctrl *leaderController) GetLeader(c *gin.Context) {
+	log := logger.From(c)
+
+	lease, ok, err := ctrl.elector.Lease(c.Request.Context())
+	if err != nil {
+		log.Errorw("Failed to read leader lease", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to read leader"})
+		return
+	}
+
+	result := gin.H{
+		"instance":  ctrl.elector.Holder(),
+		"is_leader": ctrl.elector.IsLeader(),
+		"leader":    nil,
+	}
+	if ok {
+		result["leader"] = lease
+		result["expired"] = !lease.ExpiresAt.After(time.Now())
+	}
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MerchantWebhookController interface {
+	CreateEndpoint(c *gin.Context)
+	ListEndpoints(c *gin.Context)
+	DeleteEndpoint(c *gin.Context)
+	ListEndpointDeliveries(c *gin.Context)
+	ListDeliveries(c *gin.Context)
+	Redeliver(c *gin.Context)
+}
+
+type merchantWebhookController struct {
+	service service.MerchantWebhookService
+}
+
+func NewMerchantWebhookController(service service.MerchantWebhookService) MerchantWebhookController {
+	return &merchantWebhookController{
+		service: service,
+	}
+}
+
+func (ctrl *merchantWebhookController) CreateEndpoint(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid webhook endpoint request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	endpoint, err := ctrl.service.CreateEndpoint(c.Param("id"), &req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"result": endpoint})
+}
+
+func (ctrl *merchantWebhookController) ListEndpoints(c *gin.Context) {
+	endpoints, err := ctrl.service.ListEndpoints(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": endpoints})
+}
+
+func (ctrl *merchantWebhookController) DeleteEndpoint(c *gin.Context) {
+	if err := ctrl.service.DeleteEndpoint(c.Param("id"), c.Param("webhookId"), c); err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted"})
+}
+
+func (ctrl *merchantWebhookController) ListEndpointDeliveries(c *gin.Context) {
+	deliveries, err := ctrl.service.ListEndpointDeliveries(c.Param("id"), c.Param("webhookId"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": deliveries})
+}
+
+// ListDeliveries lists deliveries across all endpoints for admins; pass
+// status=DEAD for the dead letters.
+func (ctrl *merchantWebhookController) ListDeliveries(c *gin.Context) {
+	deliveries, err := ctrl.service.ListDeliveries(c.Query("status"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": deliveries})
+}
+
+func (ctrl *merchantWebhookController) Redeliver(c *gin.Context) {
+	delivery, err := ctrl.service.Redeliver(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": delivery})
+}
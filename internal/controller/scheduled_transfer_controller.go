@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/rbac"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledTransferController interface {
+	CreateSchedule(c *gin.Context)
+	ListSchedules(c *gin.Context)
+	GetSchedule(c *gin.Context)
+	PauseSchedule(c *gin.Context)
+	ResumeSchedule(c *gin.Context)
+	CancelSchedule(c *gin.Context)
+}
+
+type scheduledTransferController struct {
+	service service.ScheduledTransferService
+}
+
+func NewScheduledTransferController(service service.ScheduledTransferService) ScheduledTransferController {
+	return &scheduledTransferController{
+		service: service,
+	}
+}
+
+func (ctrl *scheduledTransferController) CreateSchedule(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.ScheduledTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid schedule request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	schedule, err := ctrl.service.CreateSchedule(&req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"schedule": schedule})
+}
+
+// ListSchedules lists the schedules of the caller's account. Staff with
+// transfers:read:any pick the account with account_id.
+func (ctrl *scheduledTransferController) ListSchedules(c *gin.Context) {
+	log := logger.From(c)
+
+	accountID := c.Query("account_id")
+	if accountID == "" {
+		accountID = auth.AccountID(c)
+	}
+	if accountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "account_id is required"})
+		return
+	}
+	if !auth.OwnsAccount(c, accountID) && !rbac.HasScope(c, constant.ScopeTransfersReadAny) {
+		log.Warnw("Forbidden schedule list request", "account_id", accountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return
+	}
+
+	schedules, err := ctrl.service.ListSchedules(accountID, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": schedules})
+}
+
+func (ctrl *scheduledTransferController) GetSchedule(c *gin.Context) {
+	schedule, err := ctrl.service.GetSchedule(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+func (ctrl *scheduledTransferController) PauseSchedule(c *gin.Context) {
+	schedule, err := ctrl.service.PauseSchedule(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+func (ctrl *scheduledTransferController) ResumeSchedule(c *gin.Context) {
+	schedule, err := ctrl.service.ResumeSchedule(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+func (ctrl *scheduledTransferController) CancelSchedule(c *gin.Context) {
+	schedule, err := ctrl.service.CancelSchedule(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferBatchController interface {
+	SubmitBatch(c *gin.Context)
+	GetBatch(c *gin.Context)
+}
+
+type transferBatchController struct {
+	service service.TransferBatchService
+}
+
+func NewTransferBatchController(service service.TransferBatchService) TransferBatchController {
+	return &transferBatchController{
+		service: service,
+	}
+}
+
+// SubmitBatch accepts a JSON batch, or CSV either as the request body
+// (Content-Type: text/csv) or as the "file" field of a multipart form, with
+// the mode in the query string.
+func (ctrl *transferBatchController) SubmitBatch(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.TransferBatchRequest
+	contentType := c.ContentType()
+	if contentType == "text/csv" || contentType == "multipart/form-data" {
+		var body io.Reader = c.Request.Body
+		if contentType == "multipart/form-data" {
+			header, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "CSV file is required"})
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				log.Errorw("Failed to open uploaded batch", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		items, err := service.ParseTransferBatchCSV(body)
+		if err != nil {
+			log.Errorw("Invalid batch CSV", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid CSV: " + err.Error()})
+			return
+		}
+		req.Mode = c.Query("mode")
+		req.Items = items
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		log.Errorw("Invalid batch request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	batch, itemErrors, err := ctrl.service.SubmitBatch(strings.TrimSpace(req.Mode), req.Items, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message, "errors": itemErrors})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"batch": batch, "errors": itemErrors})
+}
+
+func (ctrl *transferBatchController) GetBatch(c *gin.Context) {
+	batch, err := ctrl.service.GetBatch(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batch": batch})
+}
@@ -1,8 +1,13 @@
 package controller
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
 	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/rbac"
 	"payment-service/internal/model"
 	"payment-service/internal/service"
 
@@ -12,6 +17,11 @@ import (
 type TransferController interface {
 	CreateTransfer(c *gin.Context)
 	UpdateStatus(c *gin.Context)
+	GetTransfer(c *gin.Context)
+	GetHistory(c *gin.Context)
+	RefundTransfer(c *gin.Context)
+	ListTransfers(c *gin.Context)
+	ListAccountTransfers(c *gin.Context)
 }
 
 type transferController struct {
@@ -39,18 +49,19 @@ func (ctrl *transferController) CreateTransfer(c *gin.Context) {
 	transfer, err := ctrl.service.CreateTransfer(&transferRequest, c)
 	if err != nil {
 		if err.Code == http.StatusNotFound {
-			log.Warnw("Transfer failed due to account not found", "error", err)
-			c.JSON(http.StatusNotFound, gin.H{"message": "Account not found"})
+			log.Warnw("Transfer failed: not found", "error", err.Message)
+			c.JSON(http.StatusNotFound, gin.H{"message": err.Message})
 			return
 		} else {
 			log.Errorw("Transfer failed", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Transfer failed", "error": err.Message})
+			c.JSON(err.Code, gin.H{"message": "Transfer failed", "error": err.Message})
 			return
 		}
 	}
 
-	log.Infow("Transfer created successfully", "transfer_id", transfer.ID, "amount", transfer.Amount)
-	c.JSON(http.StatusCreated, gin.H{"message": "Transfer successful", "transfer": transfer})
+	response := transfer.ToResponse()
+	log.Infow("Transfer created successfully", "transfer_id", response.ID, "amount", response.Amount)
+	c.JSON(http.StatusCreated, gin.H{"message": "Transfer successful", "transfer": response})
 }
 
 func (ctrl *transferController) UpdateStatus(c *gin.Context) {
@@ -71,7 +82,7 @@ func (ctrl *transferController) UpdateStatus(c *gin.Context) {
 		return
 	}
 
-	transfer, err := ctrl.service.UpdateTransferStatus(transferID, req.Status, c)
+	transfer, err := ctrl.service.UpdateTransferStatus(transferID, req.Status, req.Reason, c)
 	if err != nil {
 		if err.Code == http.StatusNotFound {
 			log.Warnw("Transfer not found", "transfer_id", transferID, "error", err)
@@ -84,5 +95,111 @@ func (ctrl *transferController) UpdateStatus(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully", "transfer": transfer})
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully", "transfer": transfer.ToResponse()})
+}
+
+func (ctrl *transferController) GetTransfer(c *gin.Context) {
+	transfer, err := ctrl.service.GetTransfer(c.Param("id"), c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transfer": transfer.ToResponse()})
+}
+
+// RefundTransfer refunds a completed transfer. The body is optional: without
+// an amount the whole remaining amount is refunded.
+func (ctrl *transferController) RefundTransfer(c *gin.Context) {
+	log := logger.From(c)
+
+	var req model.TransferRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.Errorw("Invalid refund request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	refund, original, err := ctrl.service.RefundTransfer(c.Param("id"), &req, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": "Refund failed", "error": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Refund successful", "refund": refund.ToResponse(), "transfer": original.ToResponse()})
+}
+
+func (ctrl *transferController) GetHistory(c *gin.Context) {
+	log := logger.From(c)
+
+	transferID := c.Param("id")
+	if transferID == "" {
+		log.Errorw("Transfer ID is required for history", "error", "missing transfer ID")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Transfer ID is required"})
+		return
+	}
+
+	history, err := ctrl.service.GetTransferHistory(transferID, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+// ListTransfers lists the caller's transfers. Staff with transfers:read:any
+// see every transfer, or one account's with account_id.
+func (ctrl *transferController) ListTransfers(c *gin.Context) {
+	log := logger.From(c)
+
+	var query model.TransferListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Errorw("Invalid transfer list request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+
+	if !rbac.HasScope(c, constant.ScopeTransfersReadAny) {
+		if query.AccountID == "" {
+			query.AccountID = auth.AccountID(c)
+		}
+		if !auth.OwnsAccount(c, query.AccountID) {
+			log.Warnw("Forbidden transfer list request", "account_id", query.AccountID, "caller", auth.AccountID(c))
+			c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+			return
+		}
+	}
+
+	ctrl.listTransfers(c, &query)
+}
+
+func (ctrl *transferController) ListAccountTransfers(c *gin.Context) {
+	log := logger.From(c)
+
+	var query model.TransferListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Errorw("Invalid transfer list request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		return
+	}
+	query.AccountID = c.Param("id")
+
+	if !auth.OwnsAccount(c, query.AccountID) && !rbac.HasScope(c, constant.ScopeTransfersReadAny) {
+		log.Warnw("Forbidden transfer list request", "account_id", query.AccountID, "caller", auth.AccountID(c))
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return
+	}
+
+	ctrl.listTransfers(c, &query)
+}
+
+func (ctrl *transferController) listTransfers(c *gin.Context, query *model.TransferListQuery) {
+	transfers, err := ctrl.service.ListTransfers(query, c)
+	if err != nil {
+		c.JSON(err.Code, gin.H{"message": err.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
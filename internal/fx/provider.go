@@ -0,0 +1,86 @@
+// exchange rate providers
+package fx
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"payment-service/internal/model"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateProvider returns how many units of quote currency one unit of base
+// currency buys, as an exact decimal string.
+type RateProvider interface {
+	Rate(base, quote string) (string, error)
+}
+
+// NewProvider picks the file-backed provider when a rates file is configured,
+// otherwise rates are read from the fx_rates table.
+func NewProvider(db *gorm.DB, ratesFile string) (RateProvider, error) {
+	if ratesFile != "" {
+		return NewFileProvider(ratesFile)
+	}
+	return NewDBProvider(db), nil
+}
+
+type dbProvider struct {
+	db *gorm.DB
+}
+
+func NewDBProvider(db *gorm.DB) RateProvider {
+	return &dbProvider{db: db}
+}
+
+func (p *dbProvider) Rate(base, quote string) (string, error) {
+	var rate model.FXRate
+	err := p.db.Where("base_currency = ? AND quote_currency = ?", strings.ToUpper(base), strings.ToUpper(quote)).
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrRateNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return rate.Rate, nil
+}
+
+type fileProvider struct {
+	rates map[string]string
+}
+
+// NewFileProvider loads a JSON array of {"base_currency", "quote_currency",
+// "rate"} objects once at startup.
+func NewFileProvider(path string) (RateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.FXRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+
+	p := &fileProvider{rates: make(map[string]string, len(rates))}
+	for _, r := range rates {
+		p.rates[pairKey(r.BaseCurrency, r.QuoteCurrency)] = r.Rate
+	}
+	return p, nil
+}
+
+func (p *fileProvider) Rate(base, quote string) (string, error) {
+	rate, ok := p.rates[pairKey(base, quote)]
+	if !ok {
+		return "", ErrRateNotFound
+	}
+	return rate, nil
+}
+
+func pairKey(base, quote string) string {
+	return strings.ToUpper(base) + "/" + strings.ToUpper(quote)
+}
@@ -0,0 +1,192 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"payment-service/internal/model"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Elector campaigns for a named lease in the database, so that of all the
+// instances sharing it exactly one is the leader at a time. A leader that
+// dies stops renewing and another instance takes over once the lease lapses;
+// one that shuts down cleanly hands it over right away. Lease times come
+// from the database's clock, so instances' own clocks needn't agree.
+type Elector struct {
+	db         *gorm.DB
+	clock      dbClock
+	name       string
+	holder     string
+	ttl        time.Duration
+	renewEvery time.Duration
+
+	mu         sync.Mutex
+	leader     bool
+	validUntil time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewElector campaigns for the lease name as holder, which must be unique
+// per instance. The lease lasts ttl and is renewed every third of it.
+func NewElector(db *gorm.DB, name, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		db:         db,
+		clock:      dbClock{sqlite: db.Dialector.Name() == "sqlite"},
+		name:       name,
+		holder:     holder,
+		ttl:        ttl,
+		renewEvery: ttl / 3,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// InstanceID names this process: the host name, which is the pod name on
+// Kubernetes, and the process ID for several instances on one host.
+func InstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = uuid.New().String()
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+func (e *Elector) Start() {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.renewEvery)
+		defer ticker.Stop()
+		for {
+			if _, err := e.Campaign(context.Background()); err != nil {
+				log.Println("[LEADER] Error campaigning for", e.name, ":", err)
+			}
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops campaigning and gives up the lease if this instance holds it.
+func (e *Elector) Stop() {
+	e.once.Do(func() { close(e.stop) })
+	<-e.done
+	if err := e.Resign(context.Background()); err != nil {
+		log.Println("[LEADER] Error resigning", e.name, ":", err)
+	}
+}
+
+// IsLeader reports whether this instance holds the lease. It turns false on
+// its own if renewals stop succeeding, a renewal interval before the lease
+// could be taken over.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.validUntil)
+}
+
+// Campaign takes the lease if it is free or has lapsed, or renews it if this
+// instance already holds it, and reports whether this instance is the leader.
+func (e *Elector) Campaign(ctx context.Context) (bool, error) {
+	// Taken before the database's, so the lease is always valid for longer
+	// than this instance assumes.
+	started := time.Now()
+	now, expiresAt := e.clock.now(), e.clock.after(e.ttl)
+
+	// The row lock serializes concurrent campaigns; the loser re-reads the
+	// winner's unexpired lease and updates nothing.
+	result := e.db.WithContext(ctx).Model(&model.LeaderLease{}).
+		Where("name = ? AND (holder = ? OR expires_at <= ?)", e.name, e.holder, now).
+		Updates(map[string]interface{}{
+			"holder":      e.holder,
+			"acquired_at": gorm.Expr("CASE WHEN holder = ? THEN acquired_at ELSE ? END", e.holder, now),
+			"renewed_at":  now,
+			"expires_at":  expiresAt,
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		result = e.db.WithContext(ctx).Model(&model.LeaderLease{}).Clauses(clause.OnConflict{DoNothing: true}).Create(map[string]interface{}{
+			"name":        e.name,
+			"holder":      e.holder,
+			"acquired_at": now,
+			"renewed_at":  now,
+			"expires_at":  expiresAt,
+		})
+	}
+
+	// Without a confirmed renewal this instance can't assume it still leads.
+	leader := result.Error == nil && result.RowsAffected == 1
+	e.setLeader(leader, started.Add(e.ttl-e.renewEvery))
+	return leader, result.Error
+}
+
+// Resign gives up the lease if this instance holds it, so another can take
+// it on its next campaign.
+func (e *Elector) Resign(ctx context.Context) error {
+	e.setLeader(false, time.Time{})
+	return e.db.WithContext(ctx).Model(&model.LeaderLease{}).
+		Where("name = ? AND holder = ?", e.name, e.holder).
+		Update("expires_at", e.clock.now()).Error
+}
+
+// Lease returns the lease as stored, whoever holds it; ok is false if no
+// instance has taken it yet.
+func (e *Elector) Lease(ctx context.Context) (lease model.LeaderLease, ok bool, err error) {
+	err = e.db.WithContext(ctx).First(&lease, "name = ?", e.name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.LeaderLease{}, false, nil
+	}
+	return lease, err == nil, err
+}
+
+func (e *Elector) setLeader(leader bool, validUntil time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader != e.leader {
+		if leader {
+			log.Println("[LEADER]", e.holder, "is now the leader for", e.name)
+		} else {
+			log.Println("[LEADER]", e.holder, "is no longer the leader for", e.name)
+		}
+	}
+	e.leader = leader
+	e.validUntil = validUntil
+}
+
+// dbClock builds SQL for the database's current time, so every instance
+// measures leases against the same clock.
+type dbClock struct {
+	sqlite bool
+}
+
+func (c dbClock) now() clause.Expr {
+	if c.sqlite {
+		return gorm.Expr("strftime('%Y-%m-%d %H:%M:%f', 'now')")
+	}
+	return gorm.Expr("NOW()")
+}
+
+// after is the database's time d from now. SQLite has no timestamp type;
+// its times are text in the format GORM writes, which compares in order.
+func (c dbClock) after(d time.Duration) clause.Expr {
+	if c.sqlite {
+		return gorm.Expr("strftime('%Y-%m-%d %H:%M:%f', 'now', ?)", fmt.Sprintf("%+.3f seconds", d.Seconds()))
+	}
+	return gorm.Expr("NOW() + make_interval(secs => ?)", d.Seconds())
+}
@@ -0,0 +1,144 @@
+package leader_test
+
+import (
+	"context"
+	"log"
+	"payment-service/internal/leader"
+	"payment-service/internal/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLeaderTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.LeaderLease{}); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	// Every connection to :memory: would otherwise get its own database.
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+// expireLease makes the lease look like its holder stopped renewing it.
+func expireLease(db *gorm.DB) {
+	db.Model(&model.LeaderLease{}).Where("name = ?", "jobs").Update("expires_at", gorm.Expr("strftime('%Y-%m-%d %H:%M:%f', 'now', '-1 seconds')"))
+}
+
+func TestElector_OnlyOneLeader(t *testing.T) {
+	db := setupLeaderTestDB()
+	ctx := context.Background()
+	a := leader.NewElector(db, "jobs", "pod-a", time.Minute)
+	b := leader.NewElector(db, "jobs", "pod-b", time.Minute)
+
+	_, ok, err := a.Lease(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	isLeader, err := a.Campaign(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+	isLeader, err = b.Campaign(ctx)
+	assert.NoError(t, err)
+	assert.False(t, isLeader)
+
+	// Renewing keeps the lease and when it was acquired.
+	first, _, _ := a.Lease(ctx)
+	isLeader, _ = a.Campaign(ctx)
+	assert.True(t, isLeader)
+	renewed, ok, _ := a.Lease(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "pod-a", renewed.Holder)
+	assert.True(t, first.AcquiredAt.Equal(renewed.AcquiredAt))
+	assert.False(t, renewed.ExpiresAt.Before(first.ExpiresAt))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), renewed.ExpiresAt, 5*time.Second)
+
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+}
+
+func TestElector_FailsOverWhenLeaseLapses(t *testing.T) {
+	db := setupLeaderTestDB()
+	ctx := context.Background()
+	a := leader.NewElector(db, "jobs", "pod-a", time.Minute)
+	b := leader.NewElector(db, "jobs", "pod-b", time.Minute)
+
+	isLeader, _ := a.Campaign(ctx)
+	assert.True(t, isLeader)
+
+	expireLease(db)
+	isLeader, err := b.Campaign(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+
+	lease, _, _ := b.Lease(ctx)
+	assert.Equal(t, "pod-b", lease.Holder)
+
+	// The old leader finds out on its next renewal.
+	isLeader, err = a.Campaign(ctx)
+	assert.NoError(t, err)
+	assert.False(t, isLeader)
+	assert.False(t, a.IsLeader())
+}
+
+func TestElector_ResignHandsOver(t *testing.T) {
+	db := setupLeaderTestDB()
+	ctx := context.Background()
+	a := leader.NewElector(db, "jobs", "pod-a", time.Minute)
+	b := leader.NewElector(db, "jobs", "pod-b", time.Minute)
+
+	a.Start()
+	assert.Eventually(t, a.IsLeader, time.Second, 5*time.Millisecond)
+	a.Stop()
+	assert.False(t, a.IsLeader())
+
+	isLeader, err := b.Campaign(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+
+	// Resigning a lease held by someone else changes nothing.
+	assert.NoError(t, a.Resign(ctx))
+	lease, _, _ := b.Lease(ctx)
+	assert.Equal(t, "pod-b", lease.Holder)
+	assert.True(t, lease.ExpiresAt.After(time.Now()))
+}
+
+func TestElector_ConcurrentCampaigns(t *testing.T) {
+	db := setupLeaderTestDB()
+	ctx := context.Background()
+
+	electors := make([]*leader.Elector, 5)
+	for i := range electors {
+		electors[i] = leader.NewElector(db, "jobs", "pod-"+string(rune('a'+i)), time.Minute)
+	}
+
+	for round := 0; round < 3; round++ {
+		var wg sync.WaitGroup
+		for _, elector := range electors {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := elector.Campaign(ctx)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		leaders := 0
+		for _, elector := range electors {
+			if elector.IsLeader() {
+				leaders++
+			}
+		}
+		assert.Equal(t, 1, leaders, "round %d", round)
+		expireLease(db)
+	}
+}
@@ -0,0 +1,149 @@
+// double-entry ledger postings
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"payment-service/internal/constant"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnbalanced = errors.New("ledger postings do not balance")
+
+// Post validates that the entries balance per currency and writes them with
+// the given transaction.
+func Post(tx *gorm.DB, entries []model.LedgerEntry) error {
+	net := make(map[string]money.Amount)
+	for _, e := range entries {
+		if e.Amount <= 0 {
+			return fmt.Errorf("ledger entry amount must be positive, got %d", e.Amount)
+		}
+		var err error
+		if e.Direction == constant.LedgerDirectionDebit {
+			net[e.Currency], err = net[e.Currency].Add(e.Amount)
+		} else {
+			net[e.Currency], err = net[e.Currency].Sub(e.Amount)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for currency, amount := range net {
+		if amount != 0 {
+			return fmt.Errorf("%w: %s off by %d", ErrUnbalanced, currency, amount)
+		}
+	}
+
+	return tx.Create(&entries).Error
+}
+
+// PostTransfer writes the postings for a completed transfer: the origin is
+// debited in the source currency and the destination credited in the
+// destination currency. Cross-currency legs balance through the FX position.
+func PostTransfer(tx *gorm.DB, transfer *model.Transfer) error {
+	description := fmt.Sprintf("transfer %d", transfer.ID)
+	origin, destination := transfer.OriginAccountID, transfer.DestinationAccountID
+
+	entries := []model.LedgerEntry{
+		customerEntry(transfer.ID, origin, constant.LedgerDirectionDebit, transfer.Amount, transfer.Currency, description),
+	}
+
+	if transfer.Currency == transfer.DestinationCurrency && transfer.Amount == transfer.DestinationAmount {
+		entries = append(entries,
+			customerEntry(transfer.ID, destination, constant.LedgerDirectionCredit, transfer.DestinationAmount, transfer.DestinationCurrency, description))
+	} else {
+		entries = append(entries,
+			houseEntry(transfer.ID, constant.LedgerAccountFXPosition, constant.LedgerDirectionCredit, transfer.Amount, transfer.Currency, description),
+			houseEntry(transfer.ID, constant.LedgerAccountFXPosition, constant.LedgerDirectionDebit, transfer.DestinationAmount, transfer.DestinationCurrency, description),
+			customerEntry(transfer.ID, destination, constant.LedgerDirectionCredit, transfer.DestinationAmount, transfer.DestinationCurrency, description),
+		)
+	}
+
+	return Post(tx, entries)
+}
+
+// PostOpeningBalance records an account's balance as of joining the ledger,
+// offset against the opening balance equity account.
+func PostOpeningBalance(tx *gorm.DB, account *model.Account) error {
+	if account.Balance == 0 {
+		return nil
+	}
+
+	accountDirection, equityDirection := constant.LedgerDirectionCredit, constant.LedgerDirectionDebit
+	amount := account.Balance
+	if amount < 0 {
+		accountDirection, equityDirection = equityDirection, accountDirection
+		amount = -amount
+	}
+
+	id := account.ID
+	return Post(tx, []model.LedgerEntry{
+		{LedgerType: constant.LedgerAccountCustomer, AccountID: &id, Direction: accountDirection, Amount: amount, Currency: account.Currency, Description: "opening balance"},
+		{LedgerType: constant.LedgerAccountOpeningBalance, Direction: equityDirection, Amount: amount, Currency: account.Currency, Description: "opening balance"},
+	})
+}
+
+// AccountBalance derives a customer account's balance from its postings:
+// credits minus debits.
+func AccountBalance(db *gorm.DB, accountID uint) (money.Amount, error) {
+	var balance int64
+	err := db.Model(&model.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", constant.LedgerDirectionCredit).
+		Where("ledger_type = ? AND account_id = ?", constant.LedgerAccountCustomer, accountID).
+		Scan(&balance).Error
+	return money.Amount(balance), err
+}
+
+// AccountBalanceAt derives a customer account's balance from the postings
+// made before t, starting from the latest balance snapshot.
+func AccountBalanceAt(db *gorm.DB, accountID uint, t time.Time) (money.Amount, error) {
+	return balanceFrom(db, accountID, t, "<")
+}
+
+// BackfillOpeningBalances posts an opening balance for every account that
+// has a balance but no ledger history yet.
+func BackfillOpeningBalances(db *gorm.DB) error {
+	var accounts []model.Account
+	err := db.Where("balance <> 0 AND NOT EXISTS (?)",
+		db.Model(&model.LedgerEntry{}).Select("1").Where("ledger_entries.account_id = accounts.id")).
+		Find(&accounts).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range accounts {
+			if err := PostOpeningBalance(tx, &accounts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func customerEntry(transferID, accountID uint, direction string, amount money.Amount, currency, description string) model.LedgerEntry {
+	return model.LedgerEntry{
+		TransferID:  &transferID,
+		LedgerType:  constant.LedgerAccountCustomer,
+		AccountID:   &accountID,
+		Direction:   direction,
+		Amount:      amount,
+		Currency:    currency,
+		Description: description,
+	}
+}
+
+func houseEntry(transferID uint, ledgerType, direction string, amount money.Amount, currency, description string) model.LedgerEntry {
+	return model.LedgerEntry{
+		TransferID:  &transferID,
+		LedgerType:  ledgerType,
+		Direction:   direction,
+		Amount:      amount,
+		Currency:    currency,
+		Description: description,
+	}
+}
@@ -0,0 +1,76 @@
+package ledger
+
+import (
+	"errors"
+	"payment-service/internal/constant"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SnapshotSettle is how long to wait after a snapshot's cut-off before
+// taking it, so postings from transactions still open at the cut-off have
+// committed.
+const SnapshotSettle = 10 * time.Minute
+
+// TakeSnapshots records the balance before asOf of every customer account
+// with postings by then, skipping accounts that already have one. It
+// returns how many snapshots were written.
+func TakeSnapshots(db *gorm.DB, asOf time.Time) (int, error) {
+	var accountIDs []uint
+	err := db.Model(&model.LedgerEntry{}).
+		Where("ledger_type = ? AND account_id IS NOT NULL AND created_at < ?", constant.LedgerAccountCustomer, asOf).
+		Where("NOT EXISTS (?)", db.Model(&model.BalanceSnapshot{}).Select("1").
+			Where("balance_snapshots.account_id = ledger_entries.account_id AND balance_snapshots.as_of = ?", asOf)).
+		Distinct().Pluck("account_id", &accountIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, accountID := range accountIDs {
+		balance, err := balanceFrom(db, accountID, asOf, "<")
+		if err != nil {
+			return count, err
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.BalanceSnapshot{AccountID: accountID, AsOf: asOf, Balance: balance})
+		if result.Error != nil {
+			return count, result.Error
+		}
+		count += int(result.RowsAffected)
+	}
+	return count, nil
+}
+
+// BalanceAsOf is the account's balance from the postings made at or before
+// t. It starts from the latest snapshot taken at or before t.
+func BalanceAsOf(db *gorm.DB, accountID uint, t time.Time) (money.Amount, error) {
+	return balanceFrom(db, accountID, t, "<=")
+}
+
+// balanceFrom adds the postings since the latest usable snapshot to it. op
+// is how entries compare with t: "<" for before t, "<=" for at or before.
+func balanceFrom(db *gorm.DB, accountID uint, t time.Time, op string) (money.Amount, error) {
+	query := db.Model(&model.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", constant.LedgerDirectionCredit).
+		Where("ledger_type = ? AND account_id = ? AND created_at "+op+" ?", constant.LedgerAccountCustomer, accountID, t)
+
+	var snapshot model.BalanceSnapshot
+	err := db.Where("account_id = ? AND as_of "+op+" ?", accountID, t).Order("as_of DESC").First(&snapshot).Error
+	switch {
+	case err == nil:
+		query = query.Where("created_at >= ?", snapshot.AsOf)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	var sum int64
+	if err := query.Scan(&sum).Error; err != nil {
+		return 0, err
+	}
+	return snapshot.Balance.Add(money.Amount(sum))
+}
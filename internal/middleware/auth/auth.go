@@ -1,29 +1,173 @@
 package auth
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func Middleware(secret string) gin.HandlerFunc {
+const principalKey = "auth_principal"
+
+// Principal is the authenticated caller: the account the token was minted
+// for (empty for staff), its subject, role and scopes, and the token's full
+// claims.
+type Principal struct {
+	AccountID string
+	Subject   string
+	Role      string
+	Scopes    []string
+	Claims    jwt.MapClaims
+}
+
+// HasScope reports whether the principal was granted the scope. The admin
+// scope satisfies every check.
+func (p Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == constant.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Denylist reports whether an access token was revoked before its expiry.
+type Denylist interface {
+	IsRevoked(jti string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves an X-API-Key header to the principal the key
+// acts as. ok is false for unknown or revoked keys.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (principal Principal, ok bool, err error)
+}
+
+const HeaderAPIKey = "X-API-Key"
+
+// Middleware authenticates requests with a Bearer access token.
+func Middleware(issuer *Issuer, denylist Denylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		authenticateBearer(c, issuer, denylist)
+	}
+}
+
+// MiddlewareWithAPIKeys accepts an X-API-Key header as well as a Bearer
+// token, and sets the same principal either way. When the header is present
+// the key alone decides the outcome.
+func MiddlewareWithAPIKeys(issuer *Issuer, denylist Denylist, keys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderAPIKey)
+		if key == "" {
+			authenticateBearer(c, issuer, denylist)
 			return
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		_, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
+		principal, ok, err := keys.AuthenticateAPIKey(key)
 		if err != nil {
+			logger.From(c).Errorw("Failed to check API key", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+
+		SetPrincipal(c, principal)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+func authenticateBearer(c *gin.Context, issuer *Issuer, denylist Denylist) {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := issuer.Parse(token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	revoked, err := denylist.IsRevoked(claims["jti"].(string))
+	if err != nil {
+		logger.From(c).Errorw("Failed to check token denylist", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	principal, ok := principalFromClaims(claims)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	SetPrincipal(c, principal)
+	c.Next()
+}
+
+func principalFromClaims(claims jwt.MapClaims) (Principal, bool) {
+	principal := Principal{Claims: claims}
+	if id, ok := claims["id"]; ok {
+		principal.AccountID = fmt.Sprint(id)
+	}
+	principal.Subject, _ = claims["sub"].(string)
+	if principal.Subject == "" {
+		principal.Subject = principal.AccountID
+	}
+	if principal.Subject == "" {
+		return Principal{}, false
+	}
+	principal.Role, _ = claims["role"].(string)
+	if scope, _ := claims["scope"].(string); scope != "" {
+		principal.Scopes = strings.Fields(scope)
+	}
+	return principal, true
+}
+
+func SetPrincipal(c *gin.Context, principal Principal) {
+	c.Set(principalKey, principal)
+}
+
+func PrincipalFrom(c *gin.Context) (Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// AccountID returns the account ID the request was authenticated as, or an
+// empty string when there is none.
+func AccountID(c *gin.Context) string {
+	principal, _ := PrincipalFrom(c)
+	return principal.AccountID
+}
+
+// OwnsAccount reports whether the authenticated caller is the given account.
+func OwnsAccount(c *gin.Context, accountID string) bool {
+	owner := AccountID(c)
+	if owner == "" {
+		return false
+	}
+	ownerID, err1 := strconv.ParseUint(owner, 10, 64)
+	targetID, err2 := strconv.ParseUint(accountID, 10, 64)
+	if err1 != nil || err2 != nil {
+		return owner == accountID
+	}
+	return ownerID == targetID
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a token signing key identified by its kid. Verify-only keys (a
+// public key, e.g. a retired key still honoured until its tokens expire)
+// have no signing half.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey wraps a shared secret as an HS256 key.
+func NewHMACKey(id, secret string) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+}
+
+// LoadKeyFile reads an RSA or Ed25519 key from a PEM file. A private key
+// signs as RS256 or EdDSA; a public key only verifies.
+func LoadKeyFile(id, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return Key{ID: id, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: &private.PublicKey}, nil
+	}
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		if edKey, ok := private.(ed25519.PrivateKey); ok {
+			return Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: edKey, verifyKey: edKey.Public()}, nil
+		}
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: public}, nil
+	}
+
+	return Key{}, fmt.Errorf("key %q: %s is not an RSA or Ed25519 PEM key", id, path)
+}
+
+// LoadKeyFiles loads every kid => PEM path pair, in kid order.
+func LoadKeyFiles(paths map[string]string) ([]Key, error) {
+	ids := make([]string, 0, len(paths))
+	for id := range paths {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	keys := make([]Key, 0, len(ids))
+	for _, id := range ids {
+		key, err := LoadKeyFile(id, paths[id])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWK returns the public key in JWK form; false for symmetric keys.
+func (k Key) JWK() (JWK, bool) {
+	switch public := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(public),
+		}, true
+	}
+	return JWK{}, false
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Issuer mints and verifies the service's access tokens. Tokens carry the
+// subject in "sub", the account (if any) in "id", the caller's "role" and
+// space-separated "scope", plus exp, iat and a unique jti, and name
+// the key that signed them in the "kid" header. Several keys can be active
+// for verification while only one signs, so keys can be rotated.
+type Issuer struct {
+	keys        map[string]Key
+	signingKey  Key
+	allowedAlgs []string
+	accessTTL   time.Duration
+}
+
+// NewIssuer builds an issuer that signs with signingKID. Only tokens signed
+// with one of allowedAlgs verify; when empty, the algorithms of the given
+// keys are allowed.
+func NewIssuer(keys []Key, signingKID string, allowedAlgs []string, accessTTL time.Duration) (*Issuer, error) {
+	issuer := &Issuer{keys: make(map[string]Key, len(keys)), accessTTL: accessTTL}
+	for _, key := range keys {
+		if _, exists := issuer.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		issuer.keys[key.ID] = key
+	}
+
+	signingKey, ok := issuer.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", signingKID)
+	}
+	if !signingKey.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKID)
+	}
+	issuer.signingKey = signingKey
+
+	if len(allowedAlgs) == 0 {
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if alg := key.Method.Alg(); !seen[alg] {
+				seen[alg] = true
+				allowedAlgs = append(allowedAlgs, alg)
+			}
+		}
+	}
+	issuer.allowedAlgs = allowedAlgs
+
+	for _, key := range keys {
+		if !issuer.allows(key.Method.Alg()) {
+			return nil, fmt.Errorf("key %q uses %s, which is not an allowed algorithm", key.ID, key.Method.Alg())
+		}
+	}
+
+	return issuer, nil
+}
+
+func (i *Issuer) AccessTTL() time.Duration {
+	return i.accessTTL
+}
+
+type IssuedToken struct {
+	Token     string
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// Issue signs a new access token for the principal with the current
+// signing key. Staff principals have no account and get no "id" claim.
+func (i *Issuer) Issue(principal Principal) (IssuedToken, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.accessTTL)
+	jti := uuid.New().String()
+
+	subject := principal.Subject
+	if subject == "" {
+		subject = principal.AccountID
+	}
+	claims := jwt.MapClaims{
+		"sub":   subject,
+		"role":  principal.Role,
+		"scope": strings.Join(principal.Scopes, " "),
+		"iat":   now.Unix(),
+		"exp":   expiresAt.Unix(),
+		"jti":   jti,
+	}
+	if principal.AccountID != "" {
+		claims["id"] = principal.AccountID
+	}
+
+	token := jwt.NewWithClaims(i.signingKey.Method, claims)
+	token.Header["kid"] = i.signingKey.ID
+
+	signed, err := token.SignedString(i.signingKey.signKey)
+	if err != nil {
+		return IssuedToken{}, err
+	}
+	return IssuedToken{Token: signed, JTI: jti, ExpiresAt: expiresAt}, nil
+}
+
+// Parse verifies an access token against the key named by its kid and
+// returns its claims. The algorithm must be allowed and must match the key's
+// own algorithm; tokens without exp or jti are rejected.
+func (i *Issuer) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := i.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("%w: key %q does not sign with %s", ErrInvalidToken, kid, token.Method.Alg())
+		}
+		return key.verifyKey, nil
+	}, jwt.WithValidMethods(i.allowedAlgs), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+	if err != nil {
+		return nil, err
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// JWKS publishes the public halves of the asymmetric keys. Shared HMAC
+// secrets are never published.
+func (i *Issuer) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range i.keys {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(a, b int) bool { return set.Keys[a].Kid < set.Keys[b].Kid })
+	return set
+}
+
+func (i *Issuer) allows(alg string) bool {
+	for _, allowed := range i.allowedAlgs {
+		if allowed == alg {
+			return true
+		}
+	}
+	return false
+}
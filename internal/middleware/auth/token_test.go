@@ -0,0 +1,105 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"payment-service/internal/middleware/auth"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func rsaKeyFile(t *testing.T) (string, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), key
+}
+
+func edKeyFile(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	return writePEM(t, "ed.pem", "PRIVATE KEY", der)
+}
+
+func TestIssuer_RotatesKeysByKid(t *testing.T) {
+	rsaPath, _ := rsaKeyFile(t)
+	keys, err := auth.LoadKeyFiles(map[string]string{"2026-07": rsaPath, "2026-10": edKeyFile(t)})
+	assert.Nil(t, err)
+
+	oldIssuer, err := auth.NewIssuer(keys, "2026-07", nil, time.Minute)
+	assert.Nil(t, err)
+	newIssuer, err := auth.NewIssuer(keys, "2026-10", nil, time.Minute)
+	assert.Nil(t, err)
+
+	oldToken, err := oldIssuer.Issue(auth.Principal{AccountID: "1"})
+	assert.Nil(t, err)
+	newToken, err := newIssuer.Issue(auth.Principal{AccountID: "1"})
+	assert.Nil(t, err)
+
+	// After rotation tokens from the previous key still verify.
+	_, err = newIssuer.Parse(oldToken.Token)
+	assert.Nil(t, err)
+	claims, err := newIssuer.Parse(newToken.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", claims["sub"])
+
+	jwks := newIssuer.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+	assert.Equal(t, "EdDSA", jwks.Keys[1].Alg)
+}
+
+func TestIssuer_RejectsDisallowedAlgorithms(t *testing.T) {
+	rsaPath, rsaKey := rsaKeyFile(t)
+	keys, err := auth.LoadKeyFiles(map[string]string{"rsa": rsaPath})
+	assert.Nil(t, err)
+
+	issuer, err := auth.NewIssuer(keys, "rsa", []string{"RS256"}, time.Minute)
+	assert.Nil(t, err)
+
+	// HS256 signed with the RSA public key must not be accepted.
+	publicDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id": "1", "jti": "x", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = "rsa"
+	forgedString, _ := forged.SignedString(publicDER)
+	_, err = issuer.Parse(forgedString)
+	assert.NotNil(t, err)
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"id": "1", "jti": "x", "exp": time.Now().Add(time.Minute).Unix()})
+	none.Header["kid"] = "rsa"
+	noneString, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = issuer.Parse(noneString)
+	assert.NotNil(t, err)
+
+	// An HMAC key is refused outright when only RS256 is allowed.
+	_, err = auth.NewIssuer(append(keys, auth.NewHMACKey("hs", "secret")), "rsa", []string{"RS256"}, time.Minute)
+	assert.NotNil(t, err)
+}
+
+func TestIssuer_RejectsTokensWithoutExpiry(t *testing.T) {
+	issuer, _ := auth.NewIssuer([]auth.Key{auth.NewHMACKey("test", "test-secret")}, "test", nil, 15*time.Minute)
+
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "1", "jti": "x"})
+	legacy.Header["kid"] = "test"
+	legacyString, _ := legacy.SignedString([]byte("test-secret"))
+	_, err := issuer.Parse(legacyString)
+	assert.NotNil(t, err)
+}
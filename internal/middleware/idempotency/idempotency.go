@@ -0,0 +1,110 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+	maxKeyLength   = 255
+)
+
+// recorder tees everything the handler writes so it can be stored.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Middleware makes a handler safe to retry when the client sends an
+// Idempotency-Key header. Keys are scoped to the authenticated caller, so it
+// must run after auth.Middleware. Requests without the header pass through.
+func Middleware(idempotencyService service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		log := logger.From(c)
+
+		if len(key) > maxKeyLength {
+			log.Errorw("Invalid idempotency key", "error", "key too long")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Errorw("Failed to read request body", "error", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The path is part of the fingerprint, so a key reused for another
+		// transfer's refund isn't mistaken for a retry.
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		record, replay, serr := idempotencyService.Begin(scope(c), key, hex.EncodeToString(sum[:]), c)
+		if serr != nil {
+			c.AbortWithStatusJSON(serr.Code, gin.H{"message": serr.Message})
+			return
+		}
+
+		if replay {
+			c.Header(HeaderReplayed, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler never stores a response; free the key rather
+		// than leave it in progress until it expires.
+		defer func() {
+			if p := recover(); p != nil {
+				idempotencyService.Abandon(record, c)
+				panic(p)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		// Server errors are not final; let the client retry them.
+		if rec.Status() >= http.StatusInternalServerError {
+			idempotencyService.Abandon(record, c)
+			return
+		}
+		idempotencyService.Complete(record, rec.Status(), rec.body.Bytes(), c)
+	}
+}
+
+// scope is the caller's account, or for staff, who have none, their subject.
+func scope(c *gin.Context) string {
+	if accountID := auth.AccountID(c); accountID != "" {
+		return accountID
+	}
+	principal, _ := auth.PrincipalFrom(c)
+	return principal.Subject
+}
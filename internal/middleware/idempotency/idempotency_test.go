@@ -0,0 +1,85 @@
+package idempotency_test
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/idempotency"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIdempotencyRouter(handler gin.HandlerFunc) *gin.Engine {
+	logger.Init("test")
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.IdempotencyKey{}); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(func(c *gin.Context) {
+		auth.SetPrincipal(c, auth.Principal{Subject: c.GetHeader("X-Test-Subject")})
+		c.Next()
+	})
+	r.POST("/refund", idempotency.Middleware(service.NewIdempotencyService(db, time.Hour)), handler)
+	return r
+}
+
+func send(r *gin.Engine, subject, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/refund", strings.NewReader(`{}`))
+	req.Header.Set("X-Test-Subject", subject)
+	req.Header.Set(idempotency.HeaderKey, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_StaffKeysAreScopedBySubject(t *testing.T) {
+	calls := 0
+	r := setupIdempotencyRouter(func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"caller": c.GetHeader("X-Test-Subject")})
+	})
+
+	assert.Equal(t, http.StatusCreated, send(r, "staff:ann", "key-1").Code)
+	second := send(r, "staff:bob", "key-1")
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Empty(t, second.Header().Get(idempotency.HeaderReplayed))
+	assert.Contains(t, second.Body.String(), "staff:bob")
+	assert.Equal(t, 2, calls)
+
+	replayed := send(r, "staff:ann", "key-1")
+	assert.Equal(t, "true", replayed.Header().Get(idempotency.HeaderReplayed))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMiddleware_PanicFreesKey(t *testing.T) {
+	panics := true
+	r := setupIdempotencyRouter(func(c *gin.Context) {
+		if panics {
+			panic("boom")
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, send(r, "staff:ann", "key-1").Code)
+
+	panics = false
+	assert.Equal(t, http.StatusCreated, send(r, "staff:ann", "key-1").Code)
+}
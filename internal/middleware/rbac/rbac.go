@@ -0,0 +1,58 @@
+package rbac
+
+import (
+	"net/http"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Require only lets the request through when the authenticated principal
+// holds every one of the given scopes. It must run after auth.Middleware.
+func Require(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				logger.From(c).Warnw("Forbidden: missing scope", "scope", scope, "subject", principal.Subject, "path", c.FullPath())
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireAny is Require for routes that several kinds of caller may use: it
+// lets the request through when the principal holds at least one of the
+// given scopes.
+func RequireAny(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		for _, scope := range scopes {
+			if principal.HasScope(scope) {
+				c.Next()
+				return
+			}
+		}
+		logger.From(c).Warnw("Forbidden: missing scope", "scopes", scopes, "subject", principal.Subject, "path", c.FullPath())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	}
+}
+
+// HasScope reports whether the request's principal holds the scope.
+func HasScope(c *gin.Context, scope string) bool {
+	principal, ok := auth.PrincipalFrom(c)
+	return ok && principal.HasScope(scope)
+}
@@ -0,0 +1,64 @@
+package rbac_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/rbac"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWithRole(role string, scopes ...string) int {
+	return request(role, rbac.Require(scopes...))
+}
+
+func request(role string, require gin.HandlerFunc) int {
+	logger.Init("test")
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if role != "" {
+			auth.SetPrincipal(c, auth.Principal{Subject: "caller", Role: role, Scopes: constant.RoleScopes[role]})
+		}
+		c.Next()
+	})
+	r.GET("/resource", require, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resource", nil))
+	return w.Code
+}
+
+func TestRequire_CustomerCanMoveMoney(t *testing.T) {
+	assert.Equal(t, http.StatusOK, requestWithRole(constant.RoleCustomer, constant.ScopeTransfersWrite))
+	assert.Equal(t, http.StatusForbidden, requestWithRole(constant.RoleCustomer, constant.ScopeAccountsReadAny))
+}
+
+func TestRequire_SupportCannotMoveMoney(t *testing.T) {
+	assert.Equal(t, http.StatusOK, requestWithRole(constant.RoleSupport, constant.ScopeAccountsReadAny))
+	assert.Equal(t, http.StatusForbidden, requestWithRole(constant.RoleSupport, constant.ScopeTransfersWrite))
+}
+
+func TestRequire_AdminSatisfiesAnyScope(t *testing.T) {
+	assert.Equal(t, http.StatusOK, requestWithRole(constant.RoleAdmin, constant.ScopeTransfersWrite, constant.ScopeAccountsReadAny))
+}
+
+func TestRequire_Unauthenticated(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, requestWithRole("", constant.ScopeAccountsRead))
+}
+
+func TestRequireAny_OneScopeIsEnough(t *testing.T) {
+	require := rbac.RequireAny(constant.ScopeTransfersWrite, constant.ScopeAccountsWriteAny)
+	assert.Equal(t, http.StatusOK, request(constant.RoleCustomer, require))
+	assert.Equal(t, http.StatusOK, request(constant.RoleOperator, require))
+	assert.Equal(t, http.StatusForbidden, request(constant.RoleSupport, require))
+	assert.Equal(t, http.StatusUnauthorized, request("", require))
+}
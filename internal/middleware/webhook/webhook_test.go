@@ -0,0 +1,63 @@
+package webhook_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/webhook"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWebhookRouter(secrets ...string) *gin.Engine {
+	logger.Init("test")
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/hook", webhook.Middleware(secrets, 5*time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func sendWebhook(r *gin.Engine, body string, timestamp int64, signature string) int {
+	req := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.HeaderSignature, signature)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestWebhook_ValidSignature(t *testing.T) {
+	r := setupWebhookRouter("old-secret", "new-secret")
+	body := `{"status":"COMPLETED"}`
+	now := time.Now().Unix()
+
+	assert.Equal(t, http.StatusOK, sendWebhook(r, body, now, webhook.Sign("old-secret", now, []byte(body))))
+	assert.Equal(t, http.StatusOK, sendWebhook(r, body, now, webhook.Sign("new-secret", now, []byte(body))))
+	assert.Equal(t, http.StatusOK, sendWebhook(r, body, now, "sha256=deadbeef, "+webhook.Sign("new-secret", now, []byte(body))))
+}
+
+func TestWebhook_InvalidSignature(t *testing.T) {
+	r := setupWebhookRouter("secret")
+	body := `{"status":"COMPLETED"}`
+	now := time.Now().Unix()
+
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(r, body, now, webhook.Sign("wrong", now, []byte(body))))
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(r, `{"status":"FAILED"}`, now, webhook.Sign("secret", now, []byte(body))))
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(r, body, now, ""))
+}
+
+func TestWebhook_StaleTimestamp(t *testing.T) {
+	r := setupWebhookRouter("secret")
+	body := `{"status":"COMPLETED"}`
+	stale := time.Now().Add(-10 * time.Minute).Unix()
+
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(r, body, stale, webhook.Sign("secret", stale, []byte(body))))
+}
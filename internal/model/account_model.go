@@ -1,14 +1,78 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"encoding/json"
+	"payment-service/internal/money"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Account struct {
 	gorm.Model
-	Name    string  `gorm:"not null" json:"name"`
-	Balance float64 `gorm:"not null" json:"balance"`
+	Name     string       `gorm:"not null" json:"name"`
+	Currency string       `gorm:"type:char(3);not null;default:'USD'" json:"currency"`
+	Balance  money.Amount `gorm:"type:bigint;not null" json:"-"`
+	// HeldBalance is reserved by pending outgoing transfers.
+	HeldBalance money.Amount `gorm:"type:bigint;not null;default:0" json:"-"`
+	// Status is ACTIVE, FROZEN or CLOSED; only active accounts can send or
+	// receive transfers.
+	Status   string     `gorm:"type:varchar(20);not null;default:'ACTIVE';index" json:"status"`
+	ClosedAt *time.Time `json:"closed_at"`
+}
+
+// AvailableBalance is what the account can still commit to new transfers.
+func (a Account) AvailableBalance() money.Amount {
+	return a.Balance - a.HeldBalance
 }
 
+func (a Account) ToResponse() AccountResponse {
+	currency := money.CurrencyFor(a.Currency)
+	return AccountResponse{
+		ID:               a.ID,
+		Name:             a.Name,
+		Currency:         a.Currency,
+		Status:           a.Status,
+		Balance:          a.Balance.Format(currency),
+		AvailableBalance: a.AvailableBalance().Format(currency),
+		CreatedAt:        a.CreatedAt,
+		UpdatedAt:        a.UpdatedAt,
+		ClosedAt:         a.ClosedAt,
+	}
+}
+
+// AccountBalanceResponse is the current balance, or with AsOf set, the
+// historical one. Holds aren't kept historically, so a historical balance
+// has no AvailableBalance.
 type AccountBalanceResponse struct {
-	AccountID uint  `json:"account_id"`
-	Balance   float64 `json:"balance"`
-}
\ No newline at end of file
+	AccountID        uint       `json:"account_id"`
+	Balance          string     `json:"balance"`
+	AvailableBalance string     `json:"available_balance,omitempty"`
+	Currency         string     `json:"currency"`
+	AsOf             *time.Time `json:"as_of,omitempty"`
+}
+
+// AccountCreateRequest opens an account. OpeningBalance, if given, is in
+// the account's currency and is posted against the opening balance equity
+// account.
+type AccountCreateRequest struct {
+	Name           string      `json:"name" binding:"required"`
+	Currency       string      `json:"currency"`
+	OpeningBalance json.Number `json:"opening_balance"`
+}
+
+type AccountUpdateRequest struct {
+	Name *string `json:"name"`
+}
+
+type AccountResponse struct {
+	ID               uint       `json:"id"`
+	Name             string     `json:"name"`
+	Currency         string     `json:"currency"`
+	Status           string     `json:"status"`
+	Balance          string     `json:"balance"`
+	AvailableBalance string     `json:"available_balance"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// APIKey lets a merchant backend authenticate as an account without a login
+// flow. Only the SHA-256 hash of the key is stored; Prefix is kept in clear
+// so a key can be recognised in listings.
+type APIKey struct {
+	ID         uint   `gorm:"primarykey"`
+	AccountID  uint   `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(255);not null"`
+	Prefix     string `gorm:"type:varchar(16);not null"`
+	KeyHash    string `gorm:"type:char(64);not null;uniqueIndex"`
+	Scopes     string `gorm:"type:varchar(255);not null"`
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+type APIKeyRequest struct {
+	AccountID uint     `json:"account_id" binding:"required"`
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	AccountID  uint       `json:"account_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
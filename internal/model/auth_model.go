@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// AccountCredential holds a login. Customer logins belong to an account;
+// staff logins (support, operator, admin) have no account and act through
+// the scopes of their role. Passwords are only stored as bcrypt hashes.
+type AccountCredential struct {
+	ID           uint   `gorm:"primarykey"`
+	AccountID    *uint  `gorm:"uniqueIndex"`
+	Role         string `gorm:"type:varchar(20);not null;default:'customer'"`
+	Username     string `gorm:"type:varchar(255);not null;uniqueIndex"`
+	PasswordHash string `gorm:"type:varchar(255);not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// RefreshToken is a long-lived, single-use token stored server-side by its
+// SHA-256 hash. Using it rotates it: it is revoked and a new one is issued.
+type RefreshToken struct {
+	ID           uint      `gorm:"primarykey"`
+	AccountID    *uint     `gorm:"index"`
+	CredentialID uint      `gorm:"not null;default:0;index"`
+	TokenHash    string    `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	RevokedAt    *time.Time
+	CreatedAt    time.Time
+}
+
+// RevokedToken is the denylist of access tokens logged out before they
+// expire, keyed by their jti. Rows can be dropped once ExpiresAt passes.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(36)"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CredentialRequest creates a login. AccountID is required for the customer
+// role and must be empty for staff roles.
+type CredentialRequest struct {
+	AccountID *uint  `json:"account_id"`
+	Username  string `json:"username" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+	Role      string `json:"role"`
+}
+
+type CredentialResponse struct {
+	ID        uint      `json:"id"`
+	AccountID *uint     `json:"account_id,omitempty"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"payment-service/internal/money"
+	"time"
+)
+
+// BalanceSnapshot is a customer account's ledger balance from every posting
+// made before AsOf. Historical balances start from the latest snapshot
+// instead of summing the account's whole history.
+type BalanceSnapshot struct {
+	ID        uint         `gorm:"primarykey"`
+	AccountID uint         `gorm:"not null;uniqueIndex:idx_balance_snapshots_account_as_of,priority:1"`
+	AsOf      time.Time    `gorm:"not null;uniqueIndex:idx_balance_snapshots_account_as_of,priority:2"`
+	Balance   money.Amount `gorm:"type:bigint;not null"`
+	CreatedAt time.Time
+}
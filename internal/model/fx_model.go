@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// FXRate is the current exchange rate from BaseCurrency to QuoteCurrency:
+// one unit of BaseCurrency buys Rate units of QuoteCurrency.
+type FXRate struct {
+	ID            uint      `gorm:"primarykey" json:"-"`
+	BaseCurrency  string    `gorm:"type:char(3);not null;uniqueIndex:idx_fx_rates_pair" json:"base_currency"`
+	QuoteCurrency string    `gorm:"type:char(3);not null;uniqueIndex:idx_fx_rates_pair" json:"quote_currency"`
+	Rate          string    `gorm:"type:varchar(32);not null" json:"rate"`
+	UpdatedAt     time.Time `json:"-"`
+}
+
+// FXQuote locks a rate for a short time so a transfer can be created at a
+// known price. A quote can be used by a single transfer.
+type FXQuote struct {
+	ID             string    `gorm:"primaryKey;type:varchar(36)"`
+	SourceCurrency string    `gorm:"type:char(3);not null"`
+	TargetCurrency string    `gorm:"type:char(3);not null"`
+	Rate           string    `gorm:"type:varchar(32);not null"`
+	ExpiresAt      time.Time `gorm:"not null"`
+	TransferID     *uint
+	CreatedAt      time.Time
+}
+
+type FXQuoteRequest struct {
+	SourceCurrency string `json:"source_currency" binding:"required"`
+	TargetCurrency string `json:"target_currency" binding:"required"`
+	SourceAmount   string `json:"source_amount"`
+}
+
+type FXQuoteResponse struct {
+	QuoteID        string    `json:"quote_id"`
+	SourceCurrency string    `json:"source_currency"`
+	TargetCurrency string    `json:"target_currency"`
+	Rate           string    `json:"rate"`
+	SourceAmount   string    `json:"source_amount,omitempty"`
+	TargetAmount   string    `json:"target_amount,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
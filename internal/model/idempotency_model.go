@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyKey remembers the outcome of a request made with an
+// Idempotency-Key header so retries can be answered without redoing the work.
+// A zero StatusCode means the original request is still being processed.
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primarykey"`
+	Scope        string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_idempotency_scope_key"`
+	Key          string    `gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_idempotency_scope_key"`
+	RequestHash  string    `gorm:"type:char(64);not null"`
+	StatusCode   int       `gorm:"not null;default:0"`
+	ResponseBody []byte    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
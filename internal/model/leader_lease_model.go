@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// LeaderLease is held by the one instance that runs a set of background
+// jobs. The holder renews it before ExpiresAt; once it lapses any instance
+// may take it over. AcquiredAt is when the current holder took it.
+type LeaderLease struct {
+	Name       string    `gorm:"type:varchar(64);primarykey" json:"name"`
+	Holder     string    `gorm:"type:varchar(255);not null" json:"holder"`
+	AcquiredAt time.Time `gorm:"not null" json:"acquired_at"`
+	RenewedAt  time.Time `gorm:"not null" json:"renewed_at"`
+	ExpiresAt  time.Time `gorm:"not null" json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"payment-service/internal/money"
+	"time"
+)
+
+// LedgerEntry is one side of a double-entry posting. Entries are append-only;
+// for every transfer the debits and credits balance within each currency.
+type LedgerEntry struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	TransferID  *uint        `gorm:"index" json:"transfer_id"`
+	LedgerType  string       `gorm:"type:varchar(32);not null" json:"ledger_type"`
+	AccountID   *uint        `gorm:"index" json:"account_id"`
+	Direction   string       `gorm:"type:varchar(6);not null" json:"direction"`
+	Amount      money.Amount `gorm:"type:bigint;not null" json:"-"`
+	Currency    string       `gorm:"type:char(3);not null" json:"currency"`
+	Description string       `gorm:"type:varchar(255)" json:"description"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type BalanceVerificationResponse struct {
+	AccountID     uint   `json:"account_id"`
+	Currency      string `json:"currency"`
+	CachedBalance string `json:"cached_balance"`
+	LedgerBalance string `json:"ledger_balance"`
+	Difference    string `json:"difference"`
+	Consistent    bool   `json:"consistent"`
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"payment-service/internal/constant"
+	"strings"
+	"time"
+)
+
+// WebhookEndpoint is a merchant callback URL that receives the events of an
+// account it subscribed to. EventTypes is space-separated. Secret signs the
+// payloads; it is only shown when the endpoint is created.
+type WebhookEndpoint struct {
+	ID         uint   `gorm:"primarykey"`
+	AccountID  uint   `gorm:"not null;index"`
+	URL        string `gorm:"type:varchar(2048);not null"`
+	EventTypes string `gorm:"type:varchar(255);not null"`
+	Secret     string `gorm:"type:varchar(64);not null"`
+	CreatedAt  time.Time
+	DisabledAt *time.Time
+}
+
+func (e WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, subscribed := range strings.Fields(e.EventTypes) {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (e WebhookEndpoint) ToResponse() WebhookEndpointResponse {
+	return WebhookEndpointResponse{
+		ID:         e.ID,
+		AccountID:  e.AccountID,
+		URL:        e.URL,
+		EventTypes: strings.Fields(e.EventTypes),
+		CreatedAt:  e.CreatedAt,
+		DisabledAt: e.DisabledAt,
+	}
+}
+
+// WebhookDelivery is one event to be sent to one endpoint, and where its
+// retries stand. An event is only queued once per endpoint.
+type WebhookDelivery struct {
+	ID               uint      `gorm:"primarykey"`
+	EndpointID       uint      `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event,priority:1"`
+	EventID          string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_webhook_deliveries_event,priority:2"`
+	EventType        string    `gorm:"type:varchar(64);not null"`
+	Payload          string    `gorm:"type:text;not null"`
+	Status           string    `gorm:"type:varchar(20);not null;index"`
+	Attempts         int       `gorm:"not null;default:0"`
+	NextAttemptAt    time.Time `gorm:"not null;index"`
+	LastResponseCode int
+	LastError        string `gorm:"type:varchar(255)"`
+	DeliveredAt      *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// WebhookDeliveryAttempt logs a single HTTP attempt of a delivery.
+// ResponseCode is 0 when no response was received.
+type WebhookDeliveryAttempt struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	DeliveryID   uint      `gorm:"not null;index" json:"delivery_id"`
+	ResponseCode int       `json:"response_code"`
+	Error        string    `gorm:"type:varchar(255)" json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type WebhookEndpointRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types" binding:"required"`
+}
+
+type WebhookEndpointResponse struct {
+	ID         uint       `json:"id"`
+	AccountID  uint       `json:"account_id"`
+	URL        string     `json:"url"`
+	EventTypes []string   `json:"event_types"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID               uint                     `json:"id"`
+	EndpointID       uint                     `json:"endpoint_id"`
+	EventID          string                   `json:"event_id"`
+	EventType        string                   `json:"event_type"`
+	Status           string                   `json:"status"`
+	Attempts         int                      `json:"attempts"`
+	NextAttemptAt    *time.Time               `json:"next_attempt_at,omitempty"`
+	LastResponseCode int                      `json:"last_response_code,omitempty"`
+	LastError        string                   `json:"last_error,omitempty"`
+	DeliveredAt      *time.Time               `json:"delivered_at,omitempty"`
+	CreatedAt        time.Time                `json:"created_at"`
+	Log              []WebhookDeliveryAttempt `json:"log,omitempty"`
+}
+
+func (d WebhookDelivery) ToResponse(log []WebhookDeliveryAttempt) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:               d.ID,
+		EndpointID:       d.EndpointID,
+		EventID:          d.EventID,
+		EventType:        d.EventType,
+		Status:           d.Status,
+		Attempts:         d.Attempts,
+		LastResponseCode: d.LastResponseCode,
+		LastError:        d.LastError,
+		DeliveredAt:      d.DeliveredAt,
+		CreatedAt:        d.CreatedAt,
+		Log:              log,
+	}
+	if d.Status == constant.WebhookDeliveryPending {
+		next := d.NextAttemptAt
+		response.NextAttemptAt = &next
+	}
+	return response
+}
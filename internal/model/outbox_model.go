@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the
+// change it describes, waiting for the relay to publish it. Payload is the
+// JSON of the event's data; events are published in ID order.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primarykey"`
+	EventID       string     `gorm:"type:varchar(36);not null;uniqueIndex"`
+	Type          string     `gorm:"type:varchar(64);not null"`
+	AggregateID   uint       `gorm:"not null;index"`
+	Payload       string     `gorm:"type:text;not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	LastError     string     `gorm:"type:varchar(255)"`
+	NextAttemptAt time.Time  `gorm:"not null"`
+	PublishedAt   *time.Time `gorm:"index"`
+	CreatedAt     time.Time
+}
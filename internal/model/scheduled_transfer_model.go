@@ -0,0 +1,89 @@
+package model
+
+import (
+	"encoding/json"
+	"payment-service/internal/money"
+	"time"
+)
+
+// ScheduledTransfer is an instruction to create a transfer at RunAt and, with
+// a Frequency, again every day, week or month after it until EndDate or
+// MaxRuns is reached. NextRunAt is nil once the schedule is over.
+type ScheduledTransfer struct {
+	ID                   uint         `gorm:"primarykey"`
+	OriginAccountID      uint         `gorm:"not null;index"`
+	DestinationAccountID uint         `gorm:"not null"`
+	Amount               money.Amount `gorm:"type:bigint;not null"`
+	Currency             string       `gorm:"type:char(3);not null"`
+	RunAt                time.Time    `gorm:"not null"`
+	Frequency            string       `gorm:"type:varchar(10)"`
+	EndDate              *time.Time
+	MaxRuns              int        `gorm:"not null;default:0"`
+	RunCount             int        `gorm:"not null;default:0"`
+	NextRunAt            *time.Time `gorm:"index:idx_scheduled_transfers_status_next_run,priority:2"`
+	Status               string     `gorm:"type:varchar(20);not null;default:'ACTIVE';index:idx_scheduled_transfers_status_next_run,priority:1"`
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+// ScheduledTransferExecution records one run of a schedule: the transfer it
+// created, or why it couldn't.
+type ScheduledTransferExecution struct {
+	ID                  uint      `gorm:"primarykey" json:"id"`
+	ScheduledTransferID uint      `gorm:"not null;uniqueIndex:idx_schedule_executions_run,priority:1" json:"-"`
+	ScheduledFor        time.Time `gorm:"not null;uniqueIndex:idx_schedule_executions_run,priority:2" json:"scheduled_for"`
+	TransferID          *uint     `json:"transfer_id,omitempty"`
+	Status              string    `gorm:"type:varchar(20);not null" json:"status"`
+	Error               string    `gorm:"type:varchar(255)" json:"error,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// ScheduledTransferRequest creates a schedule. Without a frequency the
+// transfer runs once; end_date and count only apply to recurring schedules.
+type ScheduledTransferRequest struct {
+	OriginAccountID      uint        `json:"origin_account_id" binding:"required"`
+	DestinationAccountID uint        `json:"destination_account_id" binding:"required"`
+	Amount               json.Number `json:"amount" binding:"required"`
+	RunAt                time.Time   `json:"run_at" binding:"required"`
+	Frequency            string      `json:"frequency"`
+	EndDate              *time.Time  `json:"end_date"`
+	Count                int         `json:"count"`
+}
+
+type ScheduledTransferResponse struct {
+	ID                   uint                         `json:"id"`
+	OriginAccountID      uint                         `json:"origin_account_id"`
+	DestinationAccountID uint                         `json:"destination_account_id"`
+	Amount               string                       `json:"amount"`
+	Currency             string                       `json:"currency"`
+	RunAt                time.Time                    `json:"run_at"`
+	Frequency            string                       `json:"frequency,omitempty"`
+	EndDate              *time.Time                   `json:"end_date,omitempty"`
+	Count                int                          `json:"count,omitempty"`
+	RunCount             int                          `json:"run_count"`
+	NextRunAt            *time.Time                   `json:"next_run_at,omitempty"`
+	Status               string                       `json:"status"`
+	CreatedAt            time.Time                    `json:"created_at"`
+	UpdatedAt            time.Time                    `json:"updated_at"`
+	Executions           []ScheduledTransferExecution `json:"executions,omitempty"`
+}
+
+func (s ScheduledTransfer) ToResponse() ScheduledTransferResponse {
+	return ScheduledTransferResponse{
+		ID:                   s.ID,
+		OriginAccountID:      s.OriginAccountID,
+		DestinationAccountID: s.DestinationAccountID,
+		Amount:               s.Amount.Format(money.CurrencyFor(s.Currency)),
+		Currency:             s.Currency,
+		RunAt:                s.RunAt,
+		Frequency:            s.Frequency,
+		EndDate:              s.EndDate,
+		Count:                s.MaxRuns,
+		RunCount:             s.RunCount,
+		NextRunAt:            s.NextRunAt,
+		Status:               s.Status,
+		CreatedAt:            s.CreatedAt,
+		UpdatedAt:            s.UpdatedAt,
+	}
+}
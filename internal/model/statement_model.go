@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Statement lists an account's postings over [From, To) with a running
+// balance. Amounts are in the account's currency; outgoing lines are
+// negative.
+type Statement struct {
+	AccountID      uint            `json:"account_id"`
+	AccountName    string          `json:"account_name"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance string          `json:"opening_balance"`
+	TotalIn        string          `json:"total_in"`
+	TotalOut       string          `json:"total_out"`
+	ClosingBalance string          `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+}
+
+type StatementLine struct {
+	Date                  time.Time `json:"date"`
+	TransferID            *uint     `json:"transfer_id,omitempty"`
+	Reference             string    `json:"reference"`
+	Description           string    `json:"description"`
+	CounterpartyAccountID *uint     `json:"counterparty_account_id,omitempty"`
+	CounterpartyName      string    `json:"counterparty_name,omitempty"`
+	Amount                string    `json:"amount"`
+	Balance               string    `json:"balance"`
+}
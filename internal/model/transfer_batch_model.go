@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TransferBatch is a set of transfers submitted together and processed in
+// the background. In ALL_OR_NOTHING mode every item is created in a single
+// transaction; in BEST_EFFORT mode each item stands alone. ClaimedAt is when
+// a worker last took the batch on, so a batch left behind by a crashed
+// worker can be picked up again.
+type TransferBatch struct {
+	ID          uint   `gorm:"primarykey"`
+	AccountID   uint   `gorm:"not null;index"`
+	Mode        string `gorm:"type:varchar(20);not null"`
+	Status      string `gorm:"type:varchar(20);not null;default:'PENDING';index"`
+	TotalItems  int    `gorm:"not null"`
+	ClaimedAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TransferBatchItem is one transfer of a batch as it was submitted, and the
+// transfer it created or why it couldn't.
+type TransferBatchItem struct {
+	ID                   uint   `gorm:"primarykey"`
+	BatchID              uint   `gorm:"not null;uniqueIndex:idx_transfer_batch_items_position,priority:1"`
+	Position             int    `gorm:"not null;uniqueIndex:idx_transfer_batch_items_position,priority:2"`
+	OriginAccountID      uint   `gorm:"not null"`
+	DestinationAccountID uint   `gorm:"not null"`
+	Amount               string `gorm:"type:varchar(32);not null"`
+	Currency             string `gorm:"type:char(3)"`
+	ExpiresIn            int
+	Status               string `gorm:"type:varchar(20);not null"`
+	TransferID           *uint
+	Error                string `gorm:"type:varchar(255)"`
+	UpdatedAt            time.Time
+}
+
+func (i TransferBatchItem) ToRequest() TransferRequest {
+	return TransferRequest{
+		OriginAccountID:      i.OriginAccountID,
+		DestinationAccountID: i.DestinationAccountID,
+		Amount:               json.Number(i.Amount),
+		Currency:             i.Currency,
+		ExpiresIn:            i.ExpiresIn,
+	}
+}
+
+// TransferBatchRequest submits a batch as JSON. Mode defaults to
+// ALL_OR_NOTHING.
+type TransferBatchRequest struct {
+	Mode  string            `json:"mode"`
+	Items []TransferRequest `json:"items" binding:"required"`
+}
+
+// TransferBatchItemError reports why the item at Index was rejected.
+type TransferBatchItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type TransferBatchItemResponse struct {
+	Index                int    `json:"index"`
+	OriginAccountID      uint   `json:"origin_account_id"`
+	DestinationAccountID uint   `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	Currency             string `json:"currency,omitempty"`
+	Status               string `json:"status"`
+	TransferID           *uint  `json:"transfer_id,omitempty"`
+	Error                string `json:"error,omitempty"`
+}
+
+type TransferBatchResponse struct {
+	ID          uint                        `json:"id"`
+	Mode        string                      `json:"mode"`
+	Status      string                      `json:"status"`
+	Total       int                         `json:"total"`
+	Pending     int                         `json:"pending"`
+	Succeeded   int                         `json:"succeeded"`
+	Failed      int                         `json:"failed"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	CompletedAt *time.Time                  `json:"completed_at,omitempty"`
+	Items       []TransferBatchItemResponse `json:"items,omitempty"`
+}
+
+func (i TransferBatchItem) ToResponse() TransferBatchItemResponse {
+	return TransferBatchItemResponse{
+		Index:                i.Position,
+		OriginAccountID:      i.OriginAccountID,
+		DestinationAccountID: i.DestinationAccountID,
+		Amount:               i.Amount,
+		Currency:             i.Currency,
+		Status:               i.Status,
+		TransferID:           i.TransferID,
+		Error:                i.Error,
+	}
+}
@@ -1,36 +1,128 @@
 package model
 
 import (
+	"encoding/json"
+	"payment-service/internal/constant"
+	"payment-service/internal/money"
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Transfer struct {
 	gorm.Model
-	OriginAccountID      uint      `gorm:"not null" json:"origin_account_id"`
-	DestinationAccountID uint      `gorm:"not null" json:"destination_account_id"`
-	Amount               float64   `gorm:"not null" json:"amount"`
-	Status               string    `gorm:"not null;default:'PENDING'" json:"status"`
+	OriginAccountID      uint         `gorm:"not null" json:"origin_account_id"`
+	DestinationAccountID uint         `gorm:"not null" json:"destination_account_id"`
+	Amount               money.Amount `gorm:"type:bigint;not null" json:"-"`
+	Currency             string       `gorm:"type:char(3);not null;default:'USD'" json:"currency"`
+	DestinationAmount    money.Amount `gorm:"type:bigint;not null;default:0" json:"-"`
+	DestinationCurrency  string       `gorm:"type:char(3)" json:"destination_currency"`
+	FXRate               string       `gorm:"type:varchar(32)" json:"fx_rate"`
+	QuoteID              *string      `gorm:"type:varchar(36)" json:"quote_id,omitempty"`
+	HeldAmount           money.Amount `gorm:"type:bigint;not null;default:0" json:"-"`
+	Status               string       `gorm:"not null;default:'PENDING';index:idx_transfers_status_expires_at,priority:1" json:"status"`
+	StatusReason         string       `gorm:"type:varchar(255)" json:"status_reason"`
+	ExpiresAt            *time.Time   `gorm:"index:idx_transfers_status_expires_at,priority:2" json:"expires_at"`
+	RefundOfID           *uint        `gorm:"index" json:"refund_of_id,omitempty"`
+	RefundedAmount       money.Amount `gorm:"type:bigint;not null;default:0" json:"-"`
+	RefundStatus         string       `gorm:"type:varchar(20)" json:"refund_status,omitempty"`
 }
 
 type TransferRequest struct {
-	OriginAccountID      uint    `json:"origin_account_id" binding:"required"`
-	DestinationAccountID uint    `json:"destination_account_id" binding:"required"`
-	Amount               float64 `json:"amount" binding:"required"`
+	OriginAccountID      uint        `json:"origin_account_id" binding:"required"`
+	DestinationAccountID uint        `json:"destination_account_id" binding:"required"`
+	Amount               json.Number `json:"amount" binding:"required"`
+	Currency             string      `json:"currency"`
+	QuoteID              string      `json:"quote_id"`
+	ExpiresIn            int         `json:"expires_in"` // seconds; overrides the default pending timeout
+}
+
+// TransferRefundRequest refunds all or part of a completed transfer. Amount
+// is in the original transfer's currency and defaults to what is left to
+// refund.
+type TransferRefundRequest struct {
+	Amount json.Number `json:"amount"`
+	Reason string      `json:"reason" binding:"max=255"`
 }
 
 type TransferResponse struct {
-	ID                   uint    `json:"id"`
-	OriginAccountID      uint    `json:"origin_account_id"`
-	DestinationAccountID uint    `json:"destination_account_id"`
-	Amount               float64 `json:"amount"`
-	Status               string  `json:"status"`
+	ID                   uint       `json:"id"`
+	OriginAccountID      uint       `json:"origin_account_id"`
+	DestinationAccountID uint       `json:"destination_account_id"`
+	Amount               string     `json:"amount"`
+	Currency             string     `json:"currency"`
+	DestinationAmount    string     `json:"destination_amount"`
+	DestinationCurrency  string     `json:"destination_currency"`
+	FXRate               string     `json:"fx_rate"`
+	QuoteID              string     `json:"quote_id,omitempty"`
+	Status               string     `json:"status"`
+	StatusReason         string     `json:"status_reason,omitempty"`
+	FailureReason        string     `json:"failure_reason,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+	RefundOfID           *uint      `json:"refund_of_id,omitempty"`
+	RefundedAmount       string     `json:"refunded_amount,omitempty"`
+	RefundStatus         string     `json:"refund_status,omitempty"`
 }
 
 type TransferUpdateRequest struct {
 	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason" binding:"max=255"`
 }
 
 type TransferUpdateResponse struct {
 	ID     uint   `json:"id"`
 	Status string `json:"status"`
-}
\ No newline at end of file
+}
+
+func (t Transfer) ToResponse() TransferResponse {
+	response := TransferResponse{
+		ID:                   t.ID,
+		OriginAccountID:      t.OriginAccountID,
+		DestinationAccountID: t.DestinationAccountID,
+		Amount:               t.Amount.Format(money.CurrencyFor(t.Currency)),
+		Currency:             t.Currency,
+		DestinationAmount:    t.DestinationAmount.Format(money.CurrencyFor(t.DestinationCurrency)),
+		DestinationCurrency:  t.DestinationCurrency,
+		FXRate:               t.FXRate,
+		Status:               t.Status,
+		StatusReason:         t.StatusReason,
+		CreatedAt:            t.CreatedAt,
+		UpdatedAt:            t.UpdatedAt,
+		ExpiresAt:            t.ExpiresAt,
+		RefundOfID:           t.RefundOfID,
+		RefundStatus:         t.RefundStatus,
+	}
+	if t.Status == constant.TransferStatusFailed || t.Status == constant.TransferStatusExpired {
+		response.FailureReason = t.StatusReason
+	}
+	if t.QuoteID != nil {
+		response.QuoteID = *t.QuoteID
+	}
+	if t.RefundedAmount != 0 {
+		response.RefundedAmount = t.RefundedAmount.Format(money.CurrencyFor(t.Currency))
+	}
+	return response
+}
+
+// TransferListQuery filters a transfer listing. Amount bounds are in the
+// listed account's currency; without an account, Currency must be given.
+// Status may list several statuses separated by commas.
+type TransferListQuery struct {
+	AccountID   string `form:"account_id"`
+	Status      string `form:"status"`
+	Direction   string `form:"direction"`
+	Currency    string `form:"currency"`
+	MinAmount   string `form:"min_amount"`
+	MaxAmount   string `form:"max_amount"`
+	CreatedFrom string `form:"created_from"`
+	CreatedTo   string `form:"created_to"`
+	Cursor      string `form:"cursor"`
+	Limit       int    `form:"limit"`
+}
+
+type TransferListResponse struct {
+	Result     []TransferResponse `json:"result"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// TransferStatusHistory records every status change of a transfer. The first
+// row of a transfer has an empty FromStatus.
+type TransferStatusHistory struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TransferID uint      `gorm:"not null;index" json:"transfer_id"`
+	FromStatus string    `gorm:"type:varchar(16)" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(16);not null" json:"to_status"`
+	Actor      string    `gorm:"type:varchar(64);not null" json:"actor"`
+	Reason     string    `gorm:"type:varchar(255)" json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (TransferStatusHistory) TableName() string {
+	return "transfer_status_history"
+}
@@ -0,0 +1,99 @@
+// exact fixed-point money amounts
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooManyDecimals  = errors.New("amount has more decimal places than the currency allows")
+	ErrAmountOutOfRange = errors.New("amount out of range")
+)
+
+// Amount is a monetary value held as an integer number of minor units
+// (cents for USD, whole yen for JPY). The scale comes from the currency.
+type Amount int64
+
+// Parse reads a decimal string such as "10.50" into minor units of the given
+// currency. Extra trailing zeros are accepted, extra significant digits are not.
+func Parse(s string, currency Currency) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	trimmed := strings.TrimRight(frac, "0")
+	if len(trimmed) > currency.Scale {
+		return 0, ErrTooManyDecimals
+	}
+	frac = trimmed + strings.Repeat("0", currency.Scale-len(trimmed))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, ErrAmountOutOfRange
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount(minor), nil
+}
+
+// Format renders the amount as an exact decimal string in the given currency.
+func (a Amount) Format(currency Currency) string {
+	digits := strconv.FormatUint(absUint(int64(a)), 10)
+	sign := ""
+	if a < 0 {
+		sign = "-"
+	}
+	if currency.Scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= currency.Scale {
+		digits = strings.Repeat("0", currency.Scale-len(digits)+1) + digits
+	}
+	point := len(digits) - currency.Scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Add returns a + b, failing instead of wrapping on overflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOutOfRange
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b, failing instead of wrapping on overflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrAmountOutOfRange
+	}
+	return a - b, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
@@ -0,0 +1,112 @@
+package money_test
+
+import (
+	"math"
+	"payment-service/internal/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	jpy = money.CurrencyFor("JPY")
+	usd = money.CurrencyFor("USD")
+	kwd = money.CurrencyFor("KWD")
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency money.Currency
+		want     money.Amount
+		err      error
+	}{
+		{"1500", jpy, 1500, nil},
+		{"1500.000", jpy, 1500, nil},
+		{"0", jpy, 0, nil},
+		{"10.50", usd, 1050, nil},
+		{"10.5", usd, 1050, nil},
+		{"10", usd, 1000, nil},
+		{"0.01", usd, 1, nil},
+		{" 7.25 ", usd, 725, nil},
+		{"+3.00", usd, 300, nil},
+		{"-3.99", usd, -399, nil},
+		{"1.234", kwd, 1234, nil},
+		{"0.001", kwd, 1, nil},
+		{"2.5", kwd, 2500, nil},
+		{"92233720368547758.07", usd, math.MaxInt64, nil},
+
+		{"1500.5", jpy, 0, money.ErrTooManyDecimals},
+		{"10.501", usd, 0, money.ErrTooManyDecimals},
+		{"1.2345", kwd, 0, money.ErrTooManyDecimals},
+		{"92233720368547758.08", usd, 0, money.ErrAmountOutOfRange},
+		{"", usd, 0, money.ErrInvalidAmount},
+		{"-", usd, 0, money.ErrInvalidAmount},
+		{".50", usd, 0, money.ErrInvalidAmount},
+		{"10.", usd, 0, money.ErrInvalidAmount},
+		{"1,000.00", usd, 0, money.ErrInvalidAmount},
+		{"1e3", usd, 0, money.ErrInvalidAmount},
+		{"--1", usd, 0, money.ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		got, err := money.Parse(tt.input, tt.currency)
+		assert.ErrorIs(t, err, tt.err, "Parse(%q, %s)", tt.input, tt.currency.Code)
+		assert.Equal(t, tt.want, got, "Parse(%q, %s)", tt.input, tt.currency.Code)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   money.Amount
+		currency money.Currency
+		want     string
+	}{
+		{1500, jpy, "1500"},
+		{-1500, jpy, "-1500"},
+		{0, jpy, "0"},
+		{1050, usd, "10.50"},
+		{5, usd, "0.05"},
+		{0, usd, "0.00"},
+		{-1, usd, "-0.01"},
+		{1234, kwd, "1.234"},
+		{7, kwd, "0.007"},
+		{-1000, kwd, "-1.000"},
+		{math.MaxInt64, usd, "92233720368547758.07"},
+		{math.MinInt64, usd, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.amount.Format(tt.currency), "%d in %s", tt.amount, tt.currency.Code)
+
+		// Everything Format writes, Parse reads back.
+		if tt.amount != math.MinInt64 {
+			parsed, err := money.Parse(tt.want, tt.currency)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.amount, parsed)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	sum, err := money.Amount(1050).Add(-2000)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(-950), sum)
+
+	difference, err := money.Amount(-950).Sub(-2000)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(1050), difference)
+
+	max, err := money.Amount(math.MaxInt64 - 1).Add(1)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(math.MaxInt64), max)
+
+	_, err = money.Amount(math.MaxInt64).Add(1)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+	_, err = money.Amount(math.MinInt64).Add(-1)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+	_, err = money.Amount(math.MinInt64).Sub(1)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+	_, err = money.Amount(math.MaxInt64).Sub(-1)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+	_, err = money.Amount(0).Sub(math.MinInt64)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+}
@@ -0,0 +1,42 @@
+package money
+
+import "strings"
+
+// DefaultCurrencyCode is the currency assumed when none is given.
+const DefaultCurrencyCode = "USD"
+
+type Currency struct {
+	Code  string
+	Scale int
+}
+
+// ISO 4217 currencies supported by the service, keyed by code.
+var currencies = map[string]Currency{
+	"ARS": {Code: "ARS", Scale: 2},
+	"BRL": {Code: "BRL", Scale: 2},
+	"CLP": {Code: "CLP", Scale: 0},
+	"EUR": {Code: "EUR", Scale: 2},
+	"GBP": {Code: "GBP", Scale: 2},
+	"JPY": {Code: "JPY", Scale: 0},
+	"KWD": {Code: "KWD", Scale: 3},
+	"MXN": {Code: "MXN", Scale: 2},
+	"USD": {Code: "USD", Scale: 2},
+}
+
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	return currency, ok
+}
+
+// CurrencyFor returns the registered currency for code, falling back to the
+// ISO 4217 default of two decimal places for codes we don't know about.
+func CurrencyFor(code string) Currency {
+	if currency, ok := LookupCurrency(code); ok {
+		return currency
+	}
+	return Currency{Code: strings.ToUpper(code), Scale: 2}
+}
+
+func DefaultCurrency() Currency {
+	return currencies[DefaultCurrencyCode]
+}
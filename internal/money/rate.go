@@ -0,0 +1,85 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// inversePlaces is how many decimal places Inverse keeps, since the inverse
+// of a decimal rate rarely has an exact decimal form.
+const inversePlaces = 10
+
+// Rate is an exact exchange rate kept as the decimal string it was quoted in,
+// so that it can be stored and echoed back without any float rounding.
+type Rate struct {
+	value *big.Rat
+	text  string
+}
+
+// ParseRate reads a positive decimal rate such as "0.9231".
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return Rate{}, ErrInvalidRate
+	}
+	value, ok := new(big.Rat).SetString(s)
+	if !ok || value.Sign() <= 0 {
+		return Rate{}, ErrInvalidRate
+	}
+	return Rate{value: value, text: s}, nil
+}
+
+func (r Rate) String() string {
+	return r.text
+}
+
+// Inverse is the rate for converting the other way, rounded half away from
+// zero to inversePlaces decimal places.
+func (r Rate) Inverse() (Rate, error) {
+	if r.value == nil {
+		return Rate{}, ErrInvalidRate
+	}
+	text := new(big.Rat).Inv(r.value).FloatString(inversePlaces)
+	text = strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+	return ParseRate(text)
+}
+
+// Convert turns an amount in the from currency into the to currency at this
+// rate, rounding half away from zero to the target currency's scale.
+func (r Rate) Convert(a Amount, from, to Currency) (Amount, error) {
+	if r.value == nil {
+		return 0, ErrInvalidRate
+	}
+
+	// a / 10^from.Scale * rate * 10^to.Scale
+	result := new(big.Rat).SetInt64(int64(a))
+	result.Mul(result, r.value)
+	result.Mul(result, new(big.Rat).SetInt(pow10(to.Scale)))
+	result.Quo(result, new(big.Rat).SetInt(pow10(from.Scale)))
+
+	num, den := result.Num(), result.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero: compare 2*|rem| against the denominator.
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	if twice.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, ErrAmountOutOfRange
+	}
+	return Amount(quo.Int64()), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,78 @@
+package money_test
+
+import (
+	"math"
+	"payment-service/internal/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := money.ParseRate(" 0.9231 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.9231", rate.String())
+
+	for _, input := range []string{"", "0", "0.000", "-1.5", ".5", "1.", "1e2", "abc"} {
+		_, err := money.ParseRate(input)
+		assert.ErrorIs(t, err, money.ErrInvalidRate, "ParseRate(%q)", input)
+	}
+}
+
+func TestRateConvert(t *testing.T) {
+	tests := []struct {
+		rate     string
+		amount   money.Amount
+		from, to money.Currency
+		want     money.Amount
+	}{
+		{"1", 1050, usd, usd, 1050},
+		{"0.9231", 10000, usd, usd, 9231},
+		{"150.25", 1000, usd, jpy, 1503}, // 1502.5 rounds half up, not to even
+		{"150.25", 3000, usd, jpy, 4508}, // 4507.5
+		{"150.35", 1000, usd, jpy, 1504}, // 1503.5
+		{"0.5", 5, usd, usd, 3},          // 2.5 rounds up; banker's rounding would give 2
+		{"0.5", -5, usd, usd, -3},        // and away from zero when negative
+		{"0.0066", 1, jpy, usd, 1},       // 0.66 cents
+		{"0.0066", 50, jpy, usd, 33},
+		{"0.004", 1, jpy, usd, 0}, // 0.4 cents rounds away to nothing
+		{"0.3071", 1000, usd, kwd, 3071},
+		{"3.2563", 1, kwd, usd, 0}, // 0.32 cents
+	}
+	for _, tt := range tests {
+		rate, err := money.ParseRate(tt.rate)
+		assert.NoError(t, err)
+		got, err := rate.Convert(tt.amount, tt.from, tt.to)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%d %s at %s to %s", tt.amount, tt.from.Code, tt.rate, tt.to.Code)
+	}
+}
+
+func TestRateInverse(t *testing.T) {
+	for rate, want := range map[string]string{
+		"1":       "1",
+		"0.5":     "2",
+		"0.9231":  "1.0833062507",
+		"150.25":  "0.006655574",
+		"3":       "0.3333333333",
+		"1.50000": "0.6666666667",
+	} {
+		parsed, err := money.ParseRate(rate)
+		assert.NoError(t, err)
+		inverse, err := parsed.Inverse()
+		assert.NoError(t, err)
+		assert.Equal(t, want, inverse.String(), "inverse of %s", rate)
+	}
+
+	_, err := money.Rate{}.Inverse()
+	assert.ErrorIs(t, err, money.ErrInvalidRate)
+}
+
+func TestRateConvert_Errors(t *testing.T) {
+	_, err := money.Rate{}.Convert(100, usd, jpy)
+	assert.ErrorIs(t, err, money.ErrInvalidRate)
+
+	rate, _ := money.ParseRate("1000")
+	_, err = rate.Convert(math.MaxInt64, usd, jpy)
+	assert.ErrorIs(t, err, money.ErrAmountOutOfRange)
+}
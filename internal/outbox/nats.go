@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// flushTimeout bounds how long a publish waits for the server to confirm it
+// has the message.
+const flushTimeout = 5 * time.Second
+
+// NATSPublisher publishes each event to "<prefix>.<type>", e.g.
+// "payments.transfer.completed", with the event ID in the Nats-Msg-Id header
+// so JetStream streams can deduplicate redeliveries.
+type NATSPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("payment-service outbox"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{conn: conn, prefix: subjectPrefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(p.subject(event.Type))
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	msg.Data = body
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	// Core NATS publishes are fire-and-forget; the round trip makes sure
+	// the server has the message before it is marked published.
+	if _, ok := ctx.Deadline(); ok {
+		return p.conn.FlushWithContext(ctx)
+	}
+	return p.conn.FlushTimeout(flushTimeout)
+}
+
+func (p *NATSPublisher) subject(eventType string) string {
+	if p.prefix == "" {
+		return eventType
+	}
+	return p.prefix + "." + eventType
+}
+
+func (p *NATSPublisher) Close() {
+	p.conn.Close()
+}
@@ -0,0 +1,125 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"payment-service/internal/outbox"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type natsMessage struct {
+	subject string
+	headers string
+	data    []byte
+}
+
+// startFakeNATS serves just enough of the NATS client protocol (INFO,
+// CONNECT, PING/PONG, PUB and HPUB) to stand in for a server in tests.
+func startFakeNATS(t *testing.T) (string, <-chan natsMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan natsMessage, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeNATS(conn, messages)
+		}
+	}()
+
+	return "nats://" + listener.Addr().String(), messages
+}
+
+func serveFakeNATS(conn net.Conn, messages chan<- natsMessage) {
+	defer conn.Close()
+	fmt.Fprint(conn, `INFO {"server_id":"fake","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}`+"\r\n")
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			body := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return
+			}
+			messages <- natsMessage{subject: fields[1], data: body[:size]}
+		case "HPUB":
+			headerSize, _ := strconv.Atoi(fields[len(fields)-2])
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			body := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return
+			}
+			messages <- natsMessage{subject: fields[1], headers: string(body[:headerSize]), data: body[headerSize:size]}
+		}
+	}
+}
+
+func TestNATSPublisher_Publish(t *testing.T) {
+	url, messages := startFakeNATS(t)
+
+	publisher, err := outbox.NewNATSPublisher(url, "payments")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer publisher.Close()
+
+	event := outbox.Event{
+		ID:          "2f1c6f0e-8d0a-4b7e-9f55-0d6b3c0c9a11",
+		Type:        "transfer.completed",
+		AggregateID: 42,
+		OccurredAt:  time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Data:        json.RawMessage(`{"status":"COMPLETED"}`),
+	}
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "payments.transfer.completed", msg.subject)
+		assert.Contains(t, msg.headers, "Nats-Msg-Id: "+event.ID)
+
+		var received outbox.Event
+		assert.NoError(t, json.Unmarshal(msg.data, &received))
+		assert.Equal(t, event.ID, received.ID)
+		assert.Equal(t, uint(42), received.AggregateID)
+		assert.JSONEq(t, `{"status":"COMPLETED"}`, string(received.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+	}
+}
+
+func TestNATSPublisher_ConnectFails(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err := outbox.NewNATSPublisher("nats://"+addr, "payments")
+	assert.Error(t, err)
+}
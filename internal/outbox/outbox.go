@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"payment-service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event is what publishers send: the envelope of a domain event with its
+// data as JSON. ID is unique per event, so consumers can drop duplicates;
+// the relay publishes at least once.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uint            `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Publisher delivers events to downstream systems. Publish returns once the
+// event has been handed off; an error means it will be retried.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Enqueue records an event in the outbox within tx, so it is published if
+// and only if the transaction commits.
+func Enqueue(tx *gorm.DB, eventType string, aggregateID uint, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	return tx.Create(&model.OutboxEvent{
+		EventID:       uuid.New().String(),
+		Type:          eventType,
+		AggregateID:   aggregateID,
+		Payload:       string(payload),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+func eventFrom(record *model.OutboxEvent) Event {
+	return Event{
+		ID:          record.EventID,
+		Type:        record.Type,
+		AggregateID: record.AggregateID,
+		OccurredAt:  record.CreatedAt,
+		Data:        json.RawMessage(record.Payload),
+	}
+}
@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// LogPublisher writes every event to the standard logger. It is the default
+// when no broker is configured.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(_ context.Context, event Event) error {
+	log.Println("[EVENT]", event.Type, event.ID, "aggregate", event.AggregateID, string(event.Data))
+	return nil
+}
+
+// Handler consumes an event in-process. An error fails the publish, so the
+// event is retried for every handler.
+type Handler func(ctx context.Context, event Event) error
+
+// InProcessPublisher hands events to handlers registered in the same
+// process, in the order they subscribed.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{}
+}
+
+func (p *InProcessPublisher) Subscribe(handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+func (p *InProcessPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.RLock()
+	handlers := p.handlers
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"payment-service/internal/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	relayBatchSize   = 100
+	relayMaxBackoff  = 5 * time.Minute
+	relayBaseBackoff = time.Second
+	lastErrorLength  = 255
+)
+
+// Relay publishes outbox events at least once, oldest id first. Ids are
+// assigned at insert, not at commit, so an event committed late can go out
+// after newer ones: there is no ordering guarantee across transactions. When
+// an event can't be published the relay stops at it and retries with
+// exponential backoff.
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	interval  time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+func NewRelay(db *gorm.DB, publisher Publisher, interval time.Duration) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *Relay) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.PublishPending(context.Background()); err != nil {
+				log.Println("[OUTBOX] Error publishing events:", err)
+			}
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the current pass to finish.
+func (r *Relay) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// PublishPending publishes due events until it runs out or one fails, and
+// returns how many were published. A publish failure is recorded on the
+// event, not returned.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		n, more, err := r.publishBatch(ctx)
+		published += n
+		if err != nil || !more {
+			return published, err
+		}
+	}
+}
+
+func (r *Relay) publishBatch(ctx context.Context) (int, bool, error) {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return 0, false, tx.Error
+	}
+	defer tx.Rollback()
+
+	// Locking the head of the queue keeps a second relay from publishing
+	// the same events concurrently, and in a different order.
+	var events []model.OutboxEvent
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("published_at IS NULL").
+		Order("id").
+		Limit(relayBatchSize).
+		Find(&events).Error; err != nil {
+		return 0, false, err
+	}
+
+	published := 0
+	now := time.Now().UTC()
+	for i := range events {
+		event := &events[i]
+		if event.NextAttemptAt.After(now) {
+			return published, false, tx.Commit().Error
+		}
+
+		if err := r.publisher.Publish(ctx, eventFrom(event)); err != nil {
+			event.Attempts++
+			event.LastError = truncate(err.Error(), lastErrorLength)
+			event.NextAttemptAt = now.Add(backoff(event.Attempts))
+			if err := tx.Save(event).Error; err != nil {
+				return published, false, err
+			}
+			log.Println("[OUTBOX] Publishing event", event.EventID, "failed, attempt", event.Attempts, ":", err)
+			return published, false, tx.Commit().Error
+		}
+
+		publishedAt := time.Now().UTC()
+		event.PublishedAt = &publishedAt
+		event.Attempts++
+		event.LastError = ""
+		if err := tx.Save(event).Error; err != nil {
+			return published, false, err
+		}
+		published++
+	}
+
+	return published, len(events) == relayBatchSize, tx.Commit().Error
+}
+
+func backoff(attempts int) time.Duration {
+	delay := relayBaseBackoff
+	for i := 1; i < attempts && delay < relayMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, relayMaxBackoff)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
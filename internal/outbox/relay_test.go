@@ -0,0 +1,125 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/model"
+	"payment-service/internal/outbox"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupOutboxTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.OutboxEvent{}); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// flakyPublisher fails its first few publishes and records the rest.
+type flakyPublisher struct {
+	failures  int
+	published []outbox.Event
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event outbox.Event) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func TestEnqueue_RolledBackWithTransaction(t *testing.T) {
+	db := setupOutboxTestDB()
+
+	tx := db.Begin()
+	assert.NoError(t, outbox.Enqueue(tx, "transfer.created", 1, map[string]string{"status": "PENDING"}))
+	tx.Rollback()
+
+	var count int64
+	db.Model(&model.OutboxEvent{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRelay_PublishesInOrder(t *testing.T) {
+	db := setupOutboxTestDB()
+	publisher := &flakyPublisher{}
+	relay := outbox.NewRelay(db, publisher, time.Minute)
+
+	for i, eventType := range []string{"transfer.created", "transfer.completed", "transfer.refunded"} {
+		assert.NoError(t, outbox.Enqueue(db, eventType, uint(i+1), map[string]int{"n": i}))
+	}
+
+	published, err := relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, published)
+	assert.Len(t, publisher.published, 3)
+	assert.Equal(t, "transfer.created", publisher.published[0].Type)
+	assert.Equal(t, "transfer.refunded", publisher.published[2].Type)
+	assert.JSONEq(t, `{"n":1}`, string(publisher.published[1].Data))
+	assert.NotEmpty(t, publisher.published[0].ID)
+
+	// Published events aren't sent again.
+	published, err = relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+}
+
+func TestRelay_FailureHoldsBackLaterEvents(t *testing.T) {
+	db := setupOutboxTestDB()
+	publisher := &flakyPublisher{failures: 1}
+	relay := outbox.NewRelay(db, publisher, time.Minute)
+
+	assert.NoError(t, outbox.Enqueue(db, "transfer.created", 1, nil))
+	assert.NoError(t, outbox.Enqueue(db, "transfer.completed", 1, nil))
+
+	published, err := relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Empty(t, publisher.published)
+
+	var head model.OutboxEvent
+	db.Order("id").First(&head)
+	assert.Equal(t, 1, head.Attempts)
+	assert.Equal(t, "broker unavailable", head.LastError)
+	assert.Nil(t, head.PublishedAt)
+	assert.True(t, head.NextAttemptAt.After(time.Now()))
+
+	// Still backing off.
+	published, _ = relay.PublishPending(context.Background())
+	assert.Equal(t, 0, published)
+
+	db.Model(&head).Update("next_attempt_at", time.Now().UTC().Add(-time.Second))
+	published, err = relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, "transfer.created", publisher.published[0].Type)
+	assert.Equal(t, "transfer.completed", publisher.published[1].Type)
+}
+
+func TestInProcessPublisher_DeliversToSubscribers(t *testing.T) {
+	publisher := outbox.NewInProcessPublisher()
+	var seen []string
+	publisher.Subscribe(func(_ context.Context, event outbox.Event) error {
+		seen = append(seen, "first:"+event.Type)
+		return nil
+	})
+	publisher.Subscribe(func(_ context.Context, event outbox.Event) error {
+		seen = append(seen, "second:"+event.Type)
+		return nil
+	})
+
+	assert.NoError(t, publisher.Publish(context.Background(), outbox.Event{Type: "transfer.failed"}))
+	assert.Equal(t, []string{"first:transfer.failed", "second:transfer.failed"}, seen)
+}
@@ -1,14 +1,32 @@
 package router
 
 import (
+	"payment-service/config"
+	"payment-service/internal/constant"
 	"payment-service/internal/controller"
+	"payment-service/internal/middleware/rbac"
 	"payment-service/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func AccountRouter(r *gin.RouterGroup, db *gorm.DB) {
+func AccountRouter(r *gin.RouterGroup, db *gorm.DB, cfg *config.Config) {
 	accountController := controller.NewAccountController(service.NewAccountService(db))
-	r.GET("/:id/balance", accountController.GetAccountBalance)
+	transferController := controller.NewTransferController(service.NewTransferService(db, cfg.TransferExpiry))
+	webhookController := controller.NewMerchantWebhookController(service.NewMerchantWebhookService(db, cfg.OutboundWebhookMaxAttempts, cfg.APP_ENV == "development"))
+	r.POST("/", rbac.Require(constant.ScopeAccountsWriteAny), accountController.CreateAccount)
+	r.GET("/:id", rbac.Require(constant.ScopeAccountsRead), accountController.GetAccount)
+	r.PATCH("/:id", rbac.Require(constant.ScopeAccountsWriteAny), accountController.UpdateAccount)
+	r.POST("/:id/freeze", rbac.Require(constant.ScopeAccountsWriteAny), accountController.FreezeAccount)
+	r.POST("/:id/unfreeze", rbac.Require(constant.ScopeAccountsWriteAny), accountController.UnfreezeAccount)
+	r.POST("/:id/close", rbac.Require(constant.ScopeAccountsWriteAny), accountController.CloseAccount)
+	r.GET("/:id/balance", rbac.Require(constant.ScopeAccountsRead), accountController.GetAccountBalance)
+	r.GET("/:id/balance/verify", rbac.Require(constant.ScopeAccountsRead), accountController.VerifyAccountBalance)
+	r.GET("/:id/statement", rbac.Require(constant.ScopeAccountsRead), accountController.GetStatement)
+	r.GET("/:id/transfers", rbac.Require(constant.ScopeTransfersRead), transferController.ListAccountTransfers)
+	r.POST("/:id/webhooks", rbac.RequireAny(constant.ScopeTransfersWrite, constant.ScopeAccountsWriteAny), webhookController.CreateEndpoint)
+	r.GET("/:id/webhooks", rbac.Require(constant.ScopeAccountsRead), webhookController.ListEndpoints)
+	r.DELETE("/:id/webhooks/:webhookId", rbac.RequireAny(constant.ScopeTransfersWrite, constant.ScopeAccountsWriteAny), webhookController.DeleteEndpoint)
+	r.GET("/:id/webhooks/:webhookId/deliveries", rbac.Require(constant.ScopeAccountsRead), webhookController.ListEndpointDeliveries)
 }
\ No newline at end of file
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"payment-service/internal/constant"
+	"payment-service/internal/controller"
+	"payment-service/internal/leader"
+	"payment-service/internal/middleware/rbac"
+	"payment-service/internal/service"
+)
+
+// AdminRouter serves the admin-only endpoints. The group must already be
+// authenticated.
+func AdminRouter(r *gin.RouterGroup, apiKeyService service.APIKeyService, webhookService service.MerchantWebhookService, elector *leader.Elector) {
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
+	webhookController := controller.NewMerchantWebhookController(webhookService)
+	leaderController := controller.NewLeaderController(elector)
+
+	r.Use(rbac.Require(constant.ScopeAdmin))
+	r.POST("/api-keys", apiKeyController.CreateAPIKey)
+	r.GET("/api-keys", apiKeyController.ListAPIKeys)
+	r.DELETE("/api-keys/:id", apiKeyController.RevokeAPIKey)
+	r.GET("/webhooks/deliveries", webhookController.ListDeliveries)
+	r.POST("/webhooks/deliveries/:id/redeliver", webhookController.Redeliver)
+	r.GET("/scheduler/leader", leaderController.GetLeader)
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"payment-service/internal/constant"
+	"payment-service/internal/controller"
+	"payment-service/internal/middleware/rbac"
+	"payment-service/internal/service"
+)
+
+func AuthRouter(r *gin.RouterGroup, tokenService service.TokenService, authMiddleware gin.HandlerFunc) {
+	authController := controller.NewAuthController(tokenService)
+
+	r.POST("/token", authController.Login)
+	r.POST("/refresh", authController.Refresh)
+	r.POST("/logout", authMiddleware, authController.Logout)
+	r.POST("/credentials", authMiddleware, rbac.Require(constant.ScopeAdmin), authController.CreateCredentials)
+}
+
+// TestTokenRouter exposes POST /token/:id, which signs a token for any
+// account ID without credentials. Only for development.
+func TestTokenRouter(r *gin.RouterGroup, tokenService service.TokenService) {
+	authController := controller.NewAuthController(tokenService)
+
+	r.POST("/:id", authController.IssueTestToken)
+}
+
+// WellKnownRouter serves the public signing keys so other services can
+// verify our tokens without sharing a secret.
+func WellKnownRouter(r *gin.RouterGroup, tokenService service.TokenService) {
+	authController := controller.NewAuthController(tokenService)
+
+	r.GET("/jwks.json", authController.JWKS)
+}
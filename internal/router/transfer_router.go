@@ -1,17 +1,50 @@
 package router
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"payment-service/config"
+	"payment-service/internal/constant"
 	"payment-service/internal/controller"
+	"payment-service/internal/fx"
+	"payment-service/internal/middleware/idempotency"
+	"payment-service/internal/middleware/rbac"
 	"payment-service/internal/service"
 )
 
-func TransferRouter(r *gin.RouterGroup, db *gorm.DB) {
-	transferService := service.NewTransferService(db)
+func TransferRouter(r *gin.RouterGroup, db *gorm.DB, cfg *config.Config) {
+	transferService := service.NewTransferService(db, cfg.TransferExpiry)
 	transferController := controller.NewTransferController(transferService)
 
-	r.POST("/", transferController.CreateTransfer)
-	r.POST("/:id/webhook", transferController.UpdateStatus)
-}
\ No newline at end of file
+	rates, err := fx.NewProvider(db, cfg.FXRatesFile)
+	if err != nil {
+		log.Fatal("Failed to load FX rates:", err)
+	}
+	fxController := controller.NewFXController(service.NewFXService(db, rates, cfg.FXQuoteTTL))
+
+	idempotencyService := service.NewIdempotencyService(db, cfg.IdempotencyKeyTTL)
+
+	scheduleController := controller.NewScheduledTransferController(service.NewScheduledTransferService(db, transferService))
+	batchController := controller.NewTransferBatchController(service.NewTransferBatchService(db, cfg.TransferExpiry))
+
+	r.POST("/", rbac.Require(constant.ScopeTransfersWrite), idempotency.Middleware(idempotencyService), transferController.CreateTransfer)
+	r.POST("/quote", rbac.Require(constant.ScopeTransfersWrite), fxController.CreateQuote)
+	r.GET("/", rbac.Require(constant.ScopeTransfersRead), transferController.ListTransfers)
+
+	r.POST("/batch", rbac.Require(constant.ScopeTransfersWrite), idempotency.Middleware(idempotencyService), batchController.SubmitBatch)
+	r.GET("/batch/:id", rbac.Require(constant.ScopeTransfersRead), batchController.GetBatch)
+
+	r.POST("/schedules", rbac.Require(constant.ScopeTransfersWrite), idempotency.Middleware(idempotencyService), scheduleController.CreateSchedule)
+	r.GET("/schedules", rbac.Require(constant.ScopeTransfersRead), scheduleController.ListSchedules)
+	r.GET("/schedules/:id", rbac.Require(constant.ScopeTransfersRead), scheduleController.GetSchedule)
+	r.POST("/schedules/:id/pause", rbac.Require(constant.ScopeTransfersWrite), scheduleController.PauseSchedule)
+	r.POST("/schedules/:id/resume", rbac.Require(constant.ScopeTransfersWrite), scheduleController.ResumeSchedule)
+	r.POST("/schedules/:id/cancel", rbac.Require(constant.ScopeTransfersWrite), scheduleController.CancelSchedule)
+
+	r.GET("/:id", rbac.Require(constant.ScopeTransfersRead), transferController.GetTransfer)
+	r.POST("/:id/refund", rbac.Require(constant.ScopeTransfersWrite), idempotency.Middleware(idempotencyService), transferController.RefundTransfer)
+	r.GET("/:id/history", rbac.Require(constant.ScopeTransfersRead), transferController.GetHistory)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"payment-service/config"
+	"payment-service/internal/controller"
+	"payment-service/internal/service"
+)
+
+func WebhookRouter(r *gin.RouterGroup, db *gorm.DB, cfg *config.Config) {
+	transferController := controller.NewTransferController(service.NewTransferService(db, cfg.TransferExpiry))
+
+	r.POST("/:id/webhook", transferController.UpdateStatus)
+}
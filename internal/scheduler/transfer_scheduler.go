@@ -1,45 +1,173 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"payment-service/internal/service"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
+// leadershipCheckEvery is how often a running job checks that this instance
+// is still the leader.
+const leadershipCheckEvery = time.Second
+
 type TransferScheduler interface {
 	Start()
 	Stop()
 }
 
+// Leadership tells whether this instance is the one that runs the jobs.
+type Leadership interface {
+	IsLeader() bool
+}
+
 type transferScheduler struct {
-	cron    *cron.Cron
-	service service.TransferService
+	cron               *cron.Cron
+	leadership         Leadership
+	service            service.TransferService
+	idempotencyService service.IdempotencyService
+	tokenService       service.TokenService
+	accountService     service.AccountService
+	scheduleService    service.ScheduledTransferService
+	batchService       service.TransferBatchService
+	webhookService     service.MerchantWebhookService
 }
 
-func NewTransferScheduler(service service.TransferService) TransferScheduler {
+// NewTransferScheduler schedules the background jobs on every instance, but
+// a job only does anything on the instance that leadership says is the
+// leader. A job still running when its next run comes due skips that run.
+func NewTransferScheduler(leadership Leadership, service service.TransferService, idempotencyService service.IdempotencyService, tokenService service.TokenService, accountService service.AccountService, scheduleService service.ScheduledTransferService, batchService service.TransferBatchService, webhookService service.MerchantWebhookService) TransferScheduler {
 	return &transferScheduler{
-		cron:    cron.New(cron.WithSeconds()),
-		service: service,
+		cron:               cron.New(cron.WithSeconds(), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger))),
+		leadership:         leadership,
+		service:            service,
+		idempotencyService: idempotencyService,
+		tokenService:       tokenService,
+		accountService:     accountService,
+		scheduleService:    scheduleService,
+		batchService:       batchService,
+		webhookService:     webhookService,
 	}
 }
 
 func (ts *transferScheduler) Start() {
 	// Schedule the CronExpireTransfers method to run every minute
-	_, err := ts.cron.AddFunc("@every 1m", func() {
+	_, err := ts.cron.AddFunc("@every 1m", ts.leaderOnly(func() {
 		if err := ts.service.CronExpireTransfers(); err != nil {
 			log.Println("[CRON] Error expiring transfers:", err)
 		}
-	})
+	}))
 	if err != nil {
 		log.Fatalf("[CRON] Failed to schedule transfer expiration: %v", err)
 	}
 
+	// Create the transfers of scheduled and recurring instructions that are due
+	_, err = ts.cron.AddFunc("@every 1m", ts.leaderOnly(func() {
+		if err := ts.scheduleService.CronRunDueSchedules(); err != nil {
+			log.Println("[CRON] Error running scheduled transfers:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule scheduled transfers: %v", err)
+	}
+
+	// Pick up transfer batches whose worker never started or has died
+	_, err = ts.cron.AddFunc("@every 1m", ts.whileLeader(func(ctx context.Context) {
+		if err := ts.batchService.CronResumeBatches(ctx); err != nil {
+			log.Println("[CRON] Error resuming transfer batches:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule transfer batch recovery: %v", err)
+	}
+
+	// Send merchant webhooks that are due, including retries
+	_, err = ts.cron.AddFunc("@every 10s", ts.whileLeader(func(ctx context.Context) {
+		if err := ts.webhookService.CronDeliverWebhooks(ctx); err != nil {
+			log.Println("[CRON] Error delivering webhooks:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule webhook delivery: %v", err)
+	}
+
+	// Purge expired idempotency keys once an hour
+	_, err = ts.cron.AddFunc("@every 1h", ts.leaderOnly(func() {
+		if err := ts.idempotencyService.CronPurgeExpiredKeys(); err != nil {
+			log.Println("[CRON] Error purging idempotency keys:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule idempotency key purge: %v", err)
+	}
+
+	// Drop denylist entries and refresh tokens that have expired anyway
+	_, err = ts.cron.AddFunc("@every 1h", ts.leaderOnly(func() {
+		if err := ts.tokenService.CronPurgeRevokedTokens(); err != nil {
+			log.Println("[CRON] Error purging expired tokens:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule token purge: %v", err)
+	}
+
+	// Snapshot balances for point-in-time queries; a no-op once the day's
+	// snapshots exist
+	_, err = ts.cron.AddFunc("@every 1h", ts.leaderOnly(func() {
+		if err := ts.accountService.CronSnapshotBalances(); err != nil {
+			log.Println("[CRON] Error snapshotting balances:", err)
+		}
+	}))
+	if err != nil {
+		log.Fatalf("[CRON] Failed to schedule balance snapshots: %v", err)
+	}
+
 	ts.cron.Start()
-	log.Println("[CRON] Transfer scheduler started, will expire transfers every minute")
+	log.Println("[CRON] Transfer scheduler started, will expire transfers every minute while leader")
+}
+
+// leaderOnly skips job on instances that aren't the leader.
+func (ts *transferScheduler) leaderOnly(job func()) func() {
+	return ts.whileLeader(func(context.Context) { job() })
+}
+
+// whileLeader is leaderOnly for jobs that can run for a while: their context
+// is cancelled as soon as this instance stops being the leader, so that they
+// stop between items instead of running on after another instance took over.
+func (ts *transferScheduler) whileLeader(job func(ctx context.Context)) func() {
+	return func() {
+		if !ts.leadership.IsLeader() {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			ticker := time.NewTicker(leadershipCheckEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if !ts.leadership.IsLeader() {
+						log.Println("[CRON] Lost leadership, stopping job")
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		job(ctx)
+	}
 }
 
+// Stop waits for running jobs to finish, so the lease isn't handed over
+// while one still is.
 func (ts *transferScheduler) Stop() {
-	ts.cron.Stop()
+	<-ts.cron.Stop().Done()
 	log.Println("[CRON] Transfer scheduler stopped")
 }
\ No newline at end of file
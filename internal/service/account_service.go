@@ -1,10 +1,18 @@
 package service
 
 import (
+	"log"
 	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/logger"
 	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 import (
@@ -12,7 +20,17 @@ import (
 )
 
 type AccountService interface {
+	CreateAccount(req *model.AccountCreateRequest, ctx *gin.Context) (model.AccountResponse, *ServiceError)
+	GetAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError)
+	UpdateAccount(accountID string, req *model.AccountUpdateRequest, ctx *gin.Context) (model.AccountResponse, *ServiceError)
+	FreezeAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError)
+	UnfreezeAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError)
+	CloseAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError)
 	GetAccountBalance(accountID string, ctx *gin.Context) (model.AccountBalanceResponse, *ServiceError)
+	GetAccountBalanceAsOf(accountID, asOf string, ctx *gin.Context) (model.AccountBalanceResponse, *ServiceError)
+	VerifyAccountBalance(accountID string, ctx *gin.Context) (model.BalanceVerificationResponse, *ServiceError)
+	GetStatement(accountID, from, to string, ctx *gin.Context) (model.Statement, *ServiceError)
+	CronSnapshotBalances() *ServiceError
 }
 
 type accountService struct {
@@ -22,6 +40,182 @@ func NewAccountService(db *gorm.DB) AccountService {
 	return &accountService{db: db}
 }
 
+// CreateAccount opens an active account. A non-zero opening balance is
+// posted to the ledger in the same transaction.
+func (s *accountService) CreateAccount(req *model.AccountCreateRequest, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return model.AccountResponse{}, &ServiceError{Message: "Account name is required", Code: http.StatusBadRequest}
+	}
+
+	currency := money.DefaultCurrency()
+	if req.Currency != "" {
+		var ok bool
+		if currency, ok = money.LookupCurrency(req.Currency); !ok {
+			log.Errorw("Account creation failed: Unsupported currency", "currency", req.Currency)
+			return model.AccountResponse{}, &ServiceError{Message: "Unsupported currency", Code: http.StatusBadRequest}
+		}
+	}
+
+	account := model.Account{Name: name, Currency: currency.Code, Status: constant.AccountStatusActive}
+	if req.OpeningBalance != "" {
+		balance, err := money.Parse(req.OpeningBalance.String(), currency)
+		if err != nil || balance < 0 {
+			log.Errorw("Account creation failed: Invalid opening balance", "opening_balance", req.OpeningBalance, "error", err)
+			return model.AccountResponse{}, &ServiceError{Message: "Invalid opening balance", Code: http.StatusBadRequest, Error: err}
+		}
+		account.Balance = balance
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&account).Error; err != nil {
+			return err
+		}
+		return ledger.PostOpeningBalance(tx, &account)
+	})
+	if err != nil {
+		log.Errorw("Account creation failed", "error", err)
+		return model.AccountResponse{}, &ServiceError{Message: "Unable to create account", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Account created", "account_id", account.ID, "currency", account.Currency)
+	return account.ToResponse(), nil
+}
+
+func (s *accountService) GetAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.AccountResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return model.AccountResponse{}, &ServiceError{Message: "Failed to retrieve account", Code: http.StatusInternalServerError, Error: err}
+	}
+	return account.ToResponse(), nil
+}
+
+// UpdateAccount changes the account's mutable details. Only the name can
+// change; currency and balance never do. Closed accounts can't be updated.
+func (s *accountService) UpdateAccount(accountID string, req *model.AccountUpdateRequest, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.AccountResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return model.AccountResponse{}, &ServiceError{Message: "Failed to retrieve account", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if account.Status == constant.AccountStatusClosed {
+		return model.AccountResponse{}, &ServiceError{Message: "Account is closed", Code: http.StatusConflict}
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return model.AccountResponse{}, &ServiceError{Message: "Account name is required", Code: http.StatusBadRequest}
+		}
+		account.Name = name
+	}
+
+	if err := s.db.Model(&account).Update("name", account.Name).Error; err != nil {
+		log.Errorw("Failed to update account", "account_id", account.ID, "error", err)
+		return model.AccountResponse{}, &ServiceError{Message: "Unable to update account", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Account updated", "account_id", account.ID)
+	return account.ToResponse(), nil
+}
+
+// FreezeAccount blocks an active account from sending or receiving
+// transfers. Pending transfers keep their holds.
+func (s *accountService) FreezeAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	return s.changeStatus(accountID, constant.AccountStatusActive, constant.AccountStatusFrozen, ctx)
+}
+
+func (s *accountService) UnfreezeAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	return s.changeStatus(accountID, constant.AccountStatusFrozen, constant.AccountStatusActive, ctx)
+}
+
+// CloseAccount permanently closes an active or frozen account. The balance
+// must be zero and nothing may be held by pending transfers.
+func (s *accountService) CloseAccount(accountID string, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	tx := s.db.Begin()
+
+	var account model.Account
+	if serr := lockAccount(tx, accountID, &account); serr != nil {
+		tx.Rollback()
+		return model.AccountResponse{}, serr
+	}
+
+	if account.Status == constant.AccountStatusClosed {
+		tx.Rollback()
+		return model.AccountResponse{}, &ServiceError{Message: "Account is already closed", Code: http.StatusConflict}
+	}
+
+	if account.Balance != 0 || account.HeldBalance != 0 {
+		tx.Rollback()
+		log.Warnw("Account close refused: Non-zero balance", "account_id", account.ID,
+			"balance", account.Balance, "held_balance", account.HeldBalance)
+		return model.AccountResponse{}, &ServiceError{Message: "Account balance must be zero to close it", Code: http.StatusConflict}
+	}
+
+	now := time.Now()
+	account.Status = constant.AccountStatusClosed
+	account.ClosedAt = &now
+	if err := tx.Model(&account).Updates(map[string]interface{}{"status": account.Status, "closed_at": now}).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Failed to close account", "account_id", account.ID, "error", err)
+		return model.AccountResponse{}, &ServiceError{Message: "Unable to close account", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	tx.Commit()
+	log.Infow("Account closed", "account_id", account.ID)
+	return account.ToResponse(), nil
+}
+
+func (s *accountService) changeStatus(accountID, from, to string, ctx *gin.Context) (model.AccountResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	tx := s.db.Begin()
+
+	var account model.Account
+	if serr := lockAccount(tx, accountID, &account); serr != nil {
+		tx.Rollback()
+		return model.AccountResponse{}, serr
+	}
+
+	if account.Status != from {
+		tx.Rollback()
+		return model.AccountResponse{}, &ServiceError{Message: "Account is " + account.Status + ", not " + from, Code: http.StatusConflict}
+	}
+
+	account.Status = to
+	if err := tx.Model(&account).Update("status", to).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Failed to update account status", "account_id", account.ID, "error", err)
+		return model.AccountResponse{}, &ServiceError{Message: "Unable to update account status", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	tx.Commit()
+	log.Infow("Account status changed", "account_id", account.ID, "from", from, "to", to)
+	return account.ToResponse(), nil
+}
+
+func lockAccount(tx *gorm.DB, accountID string, account *model.Account) *ServiceError {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return &ServiceError{Message: "Failed to retrieve account", Code: http.StatusInternalServerError, Error: err}
+	}
+	return nil
+}
+
 func (s *accountService) GetAccountBalance(accountID string, ctx *gin.Context) (model.AccountBalanceResponse, *ServiceError) {
 	var account model.Account
 	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
@@ -30,5 +224,106 @@ func (s *accountService) GetAccountBalance(accountID string, ctx *gin.Context) (
 		}
 		return model.AccountBalanceResponse{}, &ServiceError{Message: "Failed to retrieve account balance", Code: http.StatusInternalServerError, Error: err}
 	}
-	return model.AccountBalanceResponse{AccountID: account.ID, Balance: account.Balance}, nil
-}
\ No newline at end of file
+	return model.AccountBalanceResponse{
+		AccountID:        account.ID,
+		Balance:          account.Balance.Format(money.CurrencyFor(account.Currency)),
+		AvailableBalance: account.AvailableBalance().Format(money.CurrencyFor(account.Currency)),
+		Currency:         account.Currency,
+	}, nil
+}
+
+// GetAccountBalanceAsOf reconstructs the balance from the ledger postings
+// made at or before asOf (RFC 3339).
+func (s *accountService) GetAccountBalanceAsOf(accountID, asOf string, ctx *gin.Context) (model.AccountBalanceResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		return model.AccountBalanceResponse{}, &ServiceError{Message: "as_of must be an RFC 3339 timestamp", Code: http.StatusBadRequest, Error: err}
+	}
+	if at.After(time.Now()) {
+		return model.AccountBalanceResponse{}, &ServiceError{Message: "as_of cannot be in the future", Code: http.StatusBadRequest}
+	}
+
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.AccountBalanceResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return model.AccountBalanceResponse{}, &ServiceError{Message: "Failed to retrieve account balance", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	at = at.UTC()
+	balance, err := ledger.BalanceAsOf(s.db, account.ID, at)
+	if err != nil {
+		log.Errorw("Failed to reconstruct balance", "account_id", account.ID, "as_of", asOf, "error", err)
+		return model.AccountBalanceResponse{}, &ServiceError{Message: "Failed to retrieve account balance", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	return model.AccountBalanceResponse{
+		AccountID: account.ID,
+		Balance:   balance.Format(money.CurrencyFor(account.Currency)),
+		Currency:  account.Currency,
+		AsOf:      &at,
+	}, nil
+}
+
+// CronSnapshotBalances snapshots every account's balance as of the start of
+// the current UTC day, once that day has settled.
+func (s *accountService) CronSnapshotBalances() *ServiceError {
+	now := time.Now().UTC()
+	asOf := now.Truncate(24 * time.Hour)
+	if now.Sub(asOf) < ledger.SnapshotSettle {
+		asOf = asOf.AddDate(0, 0, -1)
+	}
+
+	count, err := ledger.TakeSnapshots(s.db, asOf)
+	if err != nil {
+		return &ServiceError{Message: "Failed to snapshot balances", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if count > 0 {
+		log.Println("[Cron] Snapshotted balances", "as_of", asOf, "count", count)
+	}
+	return nil
+}
+
+// VerifyAccountBalance recomputes the account's balance from its ledger
+// postings and compares it with the cached balance on the account row.
+func (s *accountService) VerifyAccountBalance(accountID string, ctx *gin.Context) (model.BalanceVerificationResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.BalanceVerificationResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return model.BalanceVerificationResponse{}, &ServiceError{Message: "Failed to retrieve account", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	ledgerBalance, err := ledger.AccountBalance(s.db, account.ID)
+	if err != nil {
+		log.Errorw("Failed to sum ledger entries", "account_id", account.ID, "error", err)
+		return model.BalanceVerificationResponse{}, &ServiceError{Message: "Failed to retrieve ledger balance", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	currency := money.CurrencyFor(account.Currency)
+	difference, err := account.Balance.Sub(ledgerBalance)
+	if err != nil {
+		return model.BalanceVerificationResponse{}, &ServiceError{Message: "Balance difference out of range", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if difference != 0 {
+		log.Warnw("Account balance does not match ledger", "account_id", account.ID,
+			"cached_balance", account.Balance.Format(currency), "ledger_balance", ledgerBalance.Format(currency))
+	}
+
+	return model.BalanceVerificationResponse{
+		AccountID:     account.ID,
+		Currency:      account.Currency,
+		CachedBalance: account.Balance.Format(currency),
+		LedgerBalance: ledgerBalance.Format(currency),
+		Difference:    difference.Format(currency),
+		Consistent:    difference == 0,
+	}, nil
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
 	"payment-service/internal/middleware/logger"
 	"payment-service/internal/model"
+	"payment-service/internal/money"
 	"payment-service/internal/service"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +25,7 @@ func setupAccountTestDB() *gorm.DB {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 
-	err = db.AutoMigrate(&model.Account{})
+	err = db.AutoMigrate(&model.Account{}, &model.LedgerEntry{}, &model.BalanceSnapshot{})
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
@@ -34,7 +38,7 @@ func TestGetAccountBalance_Success(t *testing.T) {
 	db := setupAccountTestDB()
 	accountService := service.NewAccountService(db)
 
-	testAccount := model.Account{Name: "Test Account", Balance: 100.0}
+	testAccount := model.Account{Name: "Test Account", Balance: money.Amount(10000)}
 	db.Create(&testAccount)
 
 	// create a mock context
@@ -44,7 +48,8 @@ func TestGetAccountBalance_Success(t *testing.T) {
 	response, err := accountService.GetAccountBalance(fmt.Sprint(testAccount.ID), ctx)
 
 	assert.Nil(t, err)
-	assert.Equal(t, float64(100.0), response.Balance)
+	assert.Equal(t, "100.00", response.Balance)
+	assert.Equal(t, "USD", response.Currency)
 }
 
 func TestGetAccountBalance_AccountNotFound(t *testing.T) {
@@ -62,4 +67,177 @@ func TestGetAccountBalance_AccountNotFound(t *testing.T) {
 	assert.Equal(t, "Account not found", err.Message)
 	assert.Equal(t, http.StatusNotFound, err.Code)
 	assert.Equal(t, model.AccountBalanceResponse{}, response)
-}
\ No newline at end of file
+}
+
+func TestVerifyAccountBalance_Consistent(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+
+	testAccount := model.Account{Name: "Test Account", Balance: money.Amount(12345)}
+	db.Create(&testAccount)
+	assert.Nil(t, ledger.BackfillOpeningBalances(db))
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	response, err := accountService.VerifyAccountBalance(fmt.Sprint(testAccount.ID), ctx)
+
+	assert.Nil(t, err)
+	assert.True(t, response.Consistent)
+	assert.Equal(t, "123.45", response.LedgerBalance)
+	assert.Equal(t, "0.00", response.Difference)
+}
+
+func TestVerifyAccountBalance_Drift(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+
+	testAccount := model.Account{Name: "Test Account", Balance: money.Amount(10000)}
+	db.Create(&testAccount)
+	assert.Nil(t, ledger.BackfillOpeningBalances(db))
+	db.Model(&testAccount).Update("balance", 10001)
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	response, err := accountService.VerifyAccountBalance(fmt.Sprint(testAccount.ID), ctx)
+
+	assert.Nil(t, err)
+	assert.False(t, response.Consistent)
+	assert.Equal(t, "100.01", response.CachedBalance)
+	assert.Equal(t, "100.00", response.LedgerBalance)
+	assert.Equal(t, "0.01", response.Difference)
+}
+
+func TestCreateAccount_PostsOpeningBalance(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+	logger.Init("test")
+
+	ctx := &gin.Context{}
+
+	account, err := accountService.CreateAccount(&model.AccountCreateRequest{Name: "Merchant", Currency: "eur", OpeningBalance: "25.50"}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "EUR", account.Currency)
+	assert.Equal(t, constant.AccountStatusActive, account.Status)
+	assert.Equal(t, "25.50", account.Balance)
+
+	ledgerBalance, ledgerErr := ledger.AccountBalance(db, account.ID)
+	assert.Nil(t, ledgerErr)
+	assert.Equal(t, money.Amount(2550), ledgerBalance)
+
+	_, err = accountService.CreateAccount(&model.AccountCreateRequest{Name: "Bad", Currency: "XXX"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+}
+
+func TestUpdateAccount_Rename(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+	logger.Init("test")
+
+	ctx := &gin.Context{}
+	created, _ := accountService.CreateAccount(&model.AccountCreateRequest{Name: "Old"}, ctx)
+
+	name := "New"
+	updated, err := accountService.UpdateAccount(fmt.Sprint(created.ID), &model.AccountUpdateRequest{Name: &name}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "New", updated.Name)
+
+	_, err = accountService.UpdateAccount("999", &model.AccountUpdateRequest{Name: &name}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
+
+func TestFreezeAndUnfreezeAccount(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+	logger.Init("test")
+
+	ctx := &gin.Context{}
+	created, _ := accountService.CreateAccount(&model.AccountCreateRequest{Name: "Test"}, ctx)
+	id := fmt.Sprint(created.ID)
+
+	frozen, err := accountService.FreezeAccount(id, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, constant.AccountStatusFrozen, frozen.Status)
+
+	_, err = accountService.FreezeAccount(id, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+
+	active, err := accountService.UnfreezeAccount(id, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, constant.AccountStatusActive, active.Status)
+}
+
+func TestCloseAccount_RequiresZeroBalance(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+	logger.Init("test")
+
+	ctx := &gin.Context{}
+	funded, _ := accountService.CreateAccount(&model.AccountCreateRequest{Name: "Funded", OpeningBalance: "1.00"}, ctx)
+	empty, _ := accountService.CreateAccount(&model.AccountCreateRequest{Name: "Empty"}, ctx)
+
+	_, err := accountService.CloseAccount(fmt.Sprint(funded.ID), ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+
+	closed, err := accountService.CloseAccount(fmt.Sprint(empty.ID), ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, constant.AccountStatusClosed, closed.Status)
+	assert.NotNil(t, closed.ClosedAt)
+
+	_, err = accountService.UnfreezeAccount(fmt.Sprint(empty.ID), ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+}
+
+func TestGetAccountBalanceAsOf_UsesSnapshots(t *testing.T) {
+	db := setupAccountTestDB()
+	accountService := service.NewAccountService(db)
+	logger.Init("test")
+
+	account := model.Account{Name: "Audited", Balance: money.Amount(4500)}
+	db.Create(&account)
+
+	posting := func(direction string, amount money.Amount, at time.Time) {
+		id := account.ID
+		db.Create(&model.LedgerEntry{LedgerType: constant.LedgerAccountCustomer, AccountID: &id, Direction: direction, Amount: amount, Currency: "USD", CreatedAt: at})
+	}
+	day := func(d, h int) time.Time { return time.Date(2026, 6, d, h, 0, 0, 0, time.UTC) }
+	posting(constant.LedgerDirectionCredit, 10000, day(29, 9))
+	posting(constant.LedgerDirectionDebit, 2500, day(30, 12))
+	posting(constant.LedgerDirectionDebit, 3000, time.Date(2026, 7, 1, 0, 0, 1, 0, time.UTC))
+
+	count, err := ledger.TakeSnapshots(db, day(30, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	count, _ = ledger.TakeSnapshots(db, day(30, 0))
+	assert.Equal(t, 0, count)
+
+	// Drop the history the snapshot covers: the answer must come from it.
+	db.Where("created_at < ?", day(30, 0)).Delete(&model.LedgerEntry{})
+
+	ctx := &gin.Context{}
+	response, serr := accountService.GetAccountBalanceAsOf(fmt.Sprint(account.ID), "2026-06-30T23:59:59Z", ctx)
+	assert.Nil(t, serr)
+	assert.Equal(t, "75.00", response.Balance)
+	assert.Empty(t, response.AvailableBalance)
+	assert.Equal(t, time.Date(2026, 6, 30, 23, 59, 59, 0, time.UTC), *response.AsOf)
+
+	response, _ = accountService.GetAccountBalanceAsOf(fmt.Sprint(account.ID), "2026-06-30T00:00:00Z", ctx)
+	assert.Equal(t, "100.00", response.Balance)
+
+	response, _ = accountService.GetAccountBalanceAsOf(fmt.Sprint(account.ID), "2026-07-02T00:00:00+02:00", ctx)
+	assert.Equal(t, "45.00", response.Balance)
+
+	_, serr = accountService.GetAccountBalanceAsOf(fmt.Sprint(account.ID), "2026-06-30", ctx)
+	assert.NotNil(t, serr)
+	assert.Equal(t, http.StatusBadRequest, serr.Code)
+
+	_, serr = accountService.GetAccountBalanceAsOf(fmt.Sprint(account.ID), time.Now().Add(time.Hour).Format(time.RFC3339), ctx)
+	assert.NotNil(t, serr)
+	assert.Equal(t, http.StatusBadRequest, serr.Code)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	statementDateLayout = "2006-01-02"
+	maxStatementDays    = 366
+)
+
+// GetStatement builds the account's statement for the whole UTC days from
+// and to, both inclusive. Lines come from the account's ledger postings, so
+// only completed transfers appear, each with its counterparty; the opening
+// balance is everything posted before the first day.
+func (s *accountService) GetStatement(accountID, from, to string, ctx *gin.Context) (model.Statement, *ServiceError) {
+	log := logger.From(ctx)
+
+	start, err := time.Parse(statementDateLayout, from)
+	if err != nil {
+		return model.Statement{}, &ServiceError{Message: "from must be a date (YYYY-MM-DD)", Code: http.StatusBadRequest, Error: err}
+	}
+	last, err := time.Parse(statementDateLayout, to)
+	if err != nil {
+		return model.Statement{}, &ServiceError{Message: "to must be a date (YYYY-MM-DD)", Code: http.StatusBadRequest, Error: err}
+	}
+	end := last.AddDate(0, 0, 1)
+	if !end.After(start) || end.Sub(start) > maxStatementDays*24*time.Hour {
+		return model.Statement{}, &ServiceError{Message: fmt.Sprintf("The statement period must be 1 to %d days", maxStatementDays), Code: http.StatusBadRequest}
+	}
+
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.Statement{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+		}
+		return model.Statement{}, &ServiceError{Message: "Failed to retrieve account", Code: http.StatusInternalServerError, Error: err}
+	}
+	currency := money.CurrencyFor(account.Currency)
+
+	opening, err := ledger.AccountBalanceAt(s.db, account.ID, start)
+	if err != nil {
+		log.Errorw("Failed to compute opening balance", "account_id", account.ID, "error", err)
+		return model.Statement{}, &ServiceError{Message: "Failed to build statement", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	var entries []model.LedgerEntry
+	err = s.db.Where("ledger_type = ? AND account_id = ? AND created_at >= ? AND created_at < ?",
+		constant.LedgerAccountCustomer, account.ID, start, end).
+		Order("created_at, id").Find(&entries).Error
+	if err != nil {
+		log.Errorw("Failed to load ledger entries", "account_id", account.ID, "error", err)
+		return model.Statement{}, &ServiceError{Message: "Failed to build statement", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	transfers, counterparties, err := s.statementParties(entries, account.ID)
+	if err != nil {
+		log.Errorw("Failed to load statement transfers", "account_id", account.ID, "error", err)
+		return model.Statement{}, &ServiceError{Message: "Failed to build statement", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	statement := model.Statement{
+		AccountID:      account.ID,
+		AccountName:    account.Name,
+		Currency:       account.Currency,
+		From:           start,
+		To:             end,
+		OpeningBalance: opening.Format(currency),
+		Lines:          make([]model.StatementLine, 0, len(entries)),
+		GeneratedAt:    time.Now().UTC(),
+	}
+
+	balance := opening
+	var totalIn, totalOut money.Amount
+	for _, entry := range entries {
+		amount := entry.Amount
+		if entry.Direction == constant.LedgerDirectionDebit {
+			amount = -amount
+			totalOut += entry.Amount
+		} else {
+			totalIn += entry.Amount
+		}
+		if balance, err = balance.Add(amount); err != nil {
+			return model.Statement{}, &ServiceError{Message: "Statement balance out of range", Code: http.StatusInternalServerError, Error: err}
+		}
+
+		line := model.StatementLine{
+			Date:        entry.CreatedAt.UTC(),
+			TransferID:  entry.TransferID,
+			Description: entry.Description,
+			Amount:      amount.Format(currency),
+			Balance:     balance.Format(currency),
+		}
+		if entry.TransferID != nil {
+			if transfer, ok := transfers[*entry.TransferID]; ok {
+				describeTransferLine(&line, &transfer, account.ID, counterparties)
+			}
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	statement.TotalIn = totalIn.Format(currency)
+	statement.TotalOut = totalOut.Format(currency)
+	statement.ClosingBalance = balance.Format(currency)
+	return statement, nil
+}
+
+// statementParties loads the transfers behind the entries and the accounts
+// on their other side, including accounts deleted since.
+func (s *accountService) statementParties(entries []model.LedgerEntry, accountID uint) (map[uint]model.Transfer, map[uint]model.Account, error) {
+	transferIDs := []uint{}
+	for _, entry := range entries {
+		if entry.TransferID != nil {
+			transferIDs = append(transferIDs, *entry.TransferID)
+		}
+	}
+
+	transfers := map[uint]model.Transfer{}
+	counterparties := map[uint]model.Account{}
+	if len(transferIDs) == 0 {
+		return transfers, counterparties, nil
+	}
+
+	var rows []model.Transfer
+	if err := s.db.Unscoped().Where("id IN ?", transferIDs).Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	accountIDs := []uint{}
+	for _, transfer := range rows {
+		transfers[transfer.ID] = transfer
+		accountIDs = append(accountIDs, counterpartyOf(&transfer, accountID))
+	}
+
+	var accounts []model.Account
+	if err := s.db.Unscoped().Where("id IN ?", accountIDs).Find(&accounts).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, account := range accounts {
+		counterparties[account.ID] = account
+	}
+	return transfers, counterparties, nil
+}
+
+func counterpartyOf(transfer *model.Transfer, accountID uint) uint {
+	if transfer.OriginAccountID == accountID {
+		return transfer.DestinationAccountID
+	}
+	return transfer.OriginAccountID
+}
+
+func describeTransferLine(line *model.StatementLine, transfer *model.Transfer, accountID uint, counterparties map[uint]model.Account) {
+	counterpartyID := counterpartyOf(transfer, accountID)
+	counterparty := counterparties[counterpartyID]
+
+	line.Reference = fmt.Sprintf("TRF-%d", transfer.ID)
+	line.CounterpartyAccountID = &counterpartyID
+	line.CounterpartyName = counterparty.Name
+
+	if transfer.OriginAccountID == accountID {
+		line.Description = "Transfer to " + counterparty.Name
+	} else {
+		line.Description = "Transfer from " + counterparty.Name
+	}
+	if transfer.Currency != transfer.DestinationCurrency {
+		line.Description += fmt.Sprintf(" (%s %s = %s %s at %s)",
+			transfer.Amount.Format(money.CurrencyFor(transfer.Currency)), transfer.Currency,
+			transfer.DestinationAmount.Format(money.CurrencyFor(transfer.DestinationCurrency)), transfer.DestinationCurrency,
+			transfer.FXRate)
+	}
+}
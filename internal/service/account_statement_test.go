@@ -0,0 +1,83 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatement_RunningBalance(t *testing.T) {
+	db := setupTransferTestDB()
+	logger.Init("test")
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	accountService := service.NewAccountService(db)
+
+	completed := func(origin, destination uint, amount string) {
+		transfer, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: origin, DestinationAccountID: destination, Amount: json.Number(amount)}, authedContext(origin))
+		assert.Nil(t, err)
+		_, err = transferService.UpdateTransferStatus(fmt.Sprint(transfer.ID), constant.TransferStatusCompleted, "", &gin.Context{})
+		assert.Nil(t, err)
+	}
+	completed(1, 2, "10.00")
+	completed(2, 1, "3.50")
+	// Pending transfers are not on the statement.
+	_, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "1.00"}, authedContext(1))
+	assert.Nil(t, err)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	statement, serr := accountService.GetStatement("1", today, today, &gin.Context{})
+	assert.Nil(t, serr)
+
+	// The opening balance backfilled by the setup was posted today too.
+	assert.Equal(t, "0.00", statement.OpeningBalance)
+	assert.Len(t, statement.Lines, 3)
+	assert.Equal(t, "100.00", statement.Lines[0].Balance)
+
+	out := statement.Lines[1]
+	assert.Equal(t, "-10.00", out.Amount)
+	assert.Equal(t, "90.00", out.Balance)
+	assert.Equal(t, "Transfer to Test Account 2", out.Description)
+	assert.Equal(t, uint(2), *out.CounterpartyAccountID)
+	assert.Equal(t, "TRF-1", out.Reference)
+
+	in := statement.Lines[2]
+	assert.Equal(t, "3.50", in.Amount)
+	assert.Equal(t, "93.50", in.Balance)
+	assert.Equal(t, "Test Account 2", in.CounterpartyName)
+
+	assert.Equal(t, "103.50", statement.TotalIn)
+	assert.Equal(t, "10.00", statement.TotalOut)
+	assert.Equal(t, "93.50", statement.ClosingBalance)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	next, serr := accountService.GetStatement("1", tomorrow, tomorrow, &gin.Context{})
+	assert.Nil(t, serr)
+	assert.Equal(t, "93.50", next.OpeningBalance)
+	assert.Empty(t, next.Lines)
+	assert.Equal(t, "93.50", next.ClosingBalance)
+}
+
+func TestGetStatement_InvalidPeriod(t *testing.T) {
+	db := setupTransferTestDB()
+	logger.Init("test")
+	accountService := service.NewAccountService(db)
+
+	for _, period := range [][2]string{{"", "2026-01-31"}, {"2026-02-01", "2026-01-31"}, {"2024-01-01", "2026-01-01"}, {"2026-01-01", "31/01/2026"}} {
+		_, err := accountService.GetStatement("1", period[0], period[1], &gin.Context{})
+		assert.NotNil(t, err, period)
+		assert.Equal(t, http.StatusBadRequest, err.Code)
+	}
+
+	_, err := accountService.GetStatement("999", "2026-01-01", "2026-01-31", &gin.Context{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix    = "pk_"
+	apiKeyShownLen  = 11
+	lastUsedGranule = time.Minute
+)
+
+type APIKeyService interface {
+	CreateAPIKey(req *model.APIKeyRequest, ctx *gin.Context) (model.APIKeyResponse, *ServiceError)
+	ListAPIKeys(accountID string, ctx *gin.Context) ([]model.APIKeyResponse, *ServiceError)
+	RevokeAPIKey(keyID string, ctx *gin.Context) *ServiceError
+	AuthenticateAPIKey(key string) (auth.Principal, bool, error)
+}
+
+type apiKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) APIKeyService {
+	return &apiKeyService{db: db}
+}
+
+// CreateAPIKey issues a key for the account. Keys act as the account, so
+// they can only hold customer scopes; with none requested they get all of
+// them. The plaintext key is only ever returned here.
+func (s *apiKeyService) CreateAPIKey(req *model.APIKeyRequest, ctx *gin.Context) (model.APIKeyResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = constant.RoleScopes[constant.RoleCustomer]
+	}
+	for _, scope := range scopes {
+		if !isCustomerScope(scope) {
+			log.Warnw("Failed to create API key: Scope not allowed", "scope", scope)
+			return model.APIKeyResponse{}, &ServiceError{Message: fmt.Sprintf("Scope %q cannot be granted to an API key", scope), Code: http.StatusBadRequest}
+		}
+	}
+
+	if err := s.db.First(&model.Account{}, req.AccountID).Error; err != nil {
+		log.Warnw("Failed to create API key: Account not found", "account_id", req.AccountID)
+		return model.APIKeyResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound, Error: err}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Errorw("Failed to create API key", "error", err)
+		return model.APIKeyResponse{}, &ServiceError{Message: "Failed to create API key", Code: http.StatusInternalServerError, Error: err}
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	apiKey := model.APIKey{
+		AccountID: req.AccountID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyShownLen],
+		KeyHash:   hashToken(key),
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if err := s.db.Create(&apiKey).Error; err != nil {
+		log.Errorw("Failed to store API key", "account_id", req.AccountID, "error", err)
+		return model.APIKeyResponse{}, &ServiceError{Message: "Failed to create API key", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Created API key", "api_key_id", apiKey.ID, "account_id", apiKey.AccountID)
+	response := apiKeyResponse(apiKey)
+	response.Key = key
+	return response, nil
+}
+
+// ListAPIKeys returns every key, newest first, optionally only those of one
+// account. Revoked keys are included.
+func (s *apiKeyService) ListAPIKeys(accountID string, ctx *gin.Context) ([]model.APIKeyResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	query := s.db.Order("id DESC")
+	if accountID != "" {
+		id, err := strconv.ParseUint(accountID, 10, 64)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid account ID", Code: http.StatusBadRequest, Error: err}
+		}
+		query = query.Where("account_id = ?", id)
+	}
+
+	var keys []model.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		log.Errorw("Failed to list API keys", "error", err)
+		return nil, &ServiceError{Message: "Failed to list API keys", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	responses := make([]model.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, apiKeyResponse(key))
+	}
+	return responses, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(keyID string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	var apiKey model.APIKey
+	if err := s.db.First(&apiKey, "id = ?", keyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ServiceError{Message: "API key not found", Code: http.StatusNotFound, Error: err}
+		}
+		log.Errorw("Failed to load API key", "api_key_id", keyID, "error", err)
+		return &ServiceError{Message: "Failed to revoke API key", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if apiKey.RevokedAt != nil {
+		return nil
+	}
+
+	if err := s.db.Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+		log.Errorw("Failed to revoke API key", "api_key_id", keyID, "error", err)
+		return &ServiceError{Message: "Failed to revoke API key", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Revoked API key", "api_key_id", apiKey.ID, "account_id", apiKey.AccountID)
+	return nil
+}
+
+// AuthenticateAPIKey looks the key up by its hash. last_used_at is only
+// written once per minute per key to keep hot keys from writing on every
+// request.
+func (s *apiKeyService) AuthenticateAPIKey(key string) (auth.Principal, bool, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return auth.Principal{}, false, nil
+	}
+
+	var apiKey model.APIKey
+	err := s.db.Where("key_hash = ? AND revoked_at IS NULL", hashToken(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auth.Principal{}, false, nil
+	}
+	if err != nil {
+		return auth.Principal{}, false, err
+	}
+
+	now := time.Now()
+	err = s.db.Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", apiKey.ID, now.Add(-lastUsedGranule)).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return auth.Principal{}, false, err
+	}
+
+	return auth.Principal{
+		AccountID: fmt.Sprint(apiKey.AccountID),
+		Subject:   fmt.Sprintf("apikey:%d", apiKey.ID),
+		Role:      constant.RoleAPIKey,
+		Scopes:    strings.Fields(apiKey.Scopes),
+	}, true, nil
+}
+
+func isCustomerScope(scope string) bool {
+	for _, allowed := range constant.RoleScopes[constant.RoleCustomer] {
+		if scope == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func apiKeyResponse(key model.APIKey) model.APIKeyResponse {
+	return model.APIKeyResponse{
+		ID:         key.ID,
+		AccountID:  key.AccountID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Fields(key.Scopes),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
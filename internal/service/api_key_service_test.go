@@ -0,0 +1,105 @@
+package service_test
+
+import (
+	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAPIKeyTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	err = db.AutoMigrate(&model.Account{}, &model.APIKey{})
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db.Create(&model.Account{Name: "Merchant"})
+
+	return db
+}
+
+func TestAPIKey_AuthenticatesAsAccount(t *testing.T) {
+	db := setupAPIKeyTestDB()
+	logger.Init("test")
+	apiKeyService := service.NewAPIKeyService(db)
+
+	ctx := &gin.Context{}
+
+	created, err := apiKeyService.CreateAPIKey(&model.APIKeyRequest{AccountID: 1, Name: "backend", Scopes: []string{constant.ScopeTransfersWrite}}, ctx)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, created.Key)
+	assert.Equal(t, created.Key[:len(created.Prefix)], created.Prefix)
+
+	var stored model.APIKey
+	db.First(&stored, created.ID)
+	assert.NotEqual(t, created.Key, stored.KeyHash)
+	assert.Nil(t, stored.LastUsedAt)
+
+	principal, ok, authErr := apiKeyService.AuthenticateAPIKey(created.Key)
+	assert.Nil(t, authErr)
+	assert.True(t, ok)
+	assert.Equal(t, "1", principal.AccountID)
+	assert.True(t, principal.HasScope(constant.ScopeTransfersWrite))
+	assert.False(t, principal.HasScope(constant.ScopeAccountsRead))
+
+	db.First(&stored, created.ID)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	_, ok, _ = apiKeyService.AuthenticateAPIKey(created.Key + "x")
+	assert.False(t, ok)
+}
+
+func TestAPIKey_Revoke(t *testing.T) {
+	db := setupAPIKeyTestDB()
+	logger.Init("test")
+	apiKeyService := service.NewAPIKeyService(db)
+
+	ctx := &gin.Context{}
+
+	created, err := apiKeyService.CreateAPIKey(&model.APIKeyRequest{AccountID: 1, Name: "backend"}, ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, constant.RoleScopes[constant.RoleCustomer], created.Scopes)
+
+	assert.Nil(t, apiKeyService.RevokeAPIKey("1", ctx))
+
+	_, ok, authErr := apiKeyService.AuthenticateAPIKey(created.Key)
+	assert.Nil(t, authErr)
+	assert.False(t, ok)
+
+	keys, err := apiKeyService.ListAPIKeys("1", ctx)
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].RevokedAt)
+	assert.Empty(t, keys[0].Key)
+
+	err = apiKeyService.RevokeAPIKey("99", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
+
+func TestAPIKey_RejectsStaffScopes(t *testing.T) {
+	db := setupAPIKeyTestDB()
+	logger.Init("test")
+	apiKeyService := service.NewAPIKeyService(db)
+
+	_, err := apiKeyService.CreateAPIKey(&model.APIKeyRequest{AccountID: 1, Name: "backend", Scopes: []string{constant.ScopeAdmin}}, &gin.Context{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = apiKeyService.CreateAPIKey(&model.APIKeyRequest{AccountID: 42, Name: "backend"}, &gin.Context{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
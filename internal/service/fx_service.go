@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"payment-service/internal/fx"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FXService interface {
+	CreateQuote(req *model.FXQuoteRequest, ctx *gin.Context) (model.FXQuoteResponse, *ServiceError)
+}
+
+type fxService struct {
+	db       *gorm.DB
+	rates    fx.RateProvider
+	quoteTTL time.Duration
+}
+
+func NewFXService(db *gorm.DB, rates fx.RateProvider, quoteTTL time.Duration) FXService {
+	return &fxService{db: db, rates: rates, quoteTTL: quoteTTL}
+}
+
+func (s *fxService) CreateQuote(req *model.FXQuoteRequest, ctx *gin.Context) (model.FXQuoteResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	source, ok := money.LookupCurrency(req.SourceCurrency)
+	if !ok {
+		log.Errorw("Quote failed: Unsupported source currency", "currency", req.SourceCurrency)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Unsupported source currency", Code: http.StatusBadRequest}
+	}
+
+	target, ok := money.LookupCurrency(req.TargetCurrency)
+	if !ok {
+		log.Errorw("Quote failed: Unsupported target currency", "currency", req.TargetCurrency)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Unsupported target currency", Code: http.StatusBadRequest}
+	}
+
+	if source.Code == target.Code {
+		log.Errorw("Quote failed: Source and target currency are the same", "currency", source.Code)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Source and target currency must differ", Code: http.StatusBadRequest}
+	}
+
+	rateText, err := s.rates.Rate(source.Code, target.Code)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			log.Warnw("Quote failed: Rate not available", "source", source.Code, "target", target.Code)
+			return model.FXQuoteResponse{}, &ServiceError{Message: "Exchange rate not available", Code: http.StatusUnprocessableEntity}
+		}
+		log.Errorw("Quote failed: Unable to load rate", "error", err)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Unable to load exchange rate", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	rate, err := money.ParseRate(rateText)
+	if err != nil {
+		log.Errorw("Quote failed: Provider returned an invalid rate", "rate", rateText, "error", err)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Unable to load exchange rate", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	quote := model.FXQuote{
+		ID:             uuid.New().String(),
+		SourceCurrency: source.Code,
+		TargetCurrency: target.Code,
+		Rate:           rate.String(),
+		ExpiresAt:      time.Now().Add(s.quoteTTL),
+	}
+
+	response := model.FXQuoteResponse{
+		QuoteID:        quote.ID,
+		SourceCurrency: quote.SourceCurrency,
+		TargetCurrency: quote.TargetCurrency,
+		Rate:           quote.Rate,
+		ExpiresAt:      quote.ExpiresAt,
+	}
+
+	if strings.TrimSpace(req.SourceAmount) != "" {
+		amount, err := money.Parse(req.SourceAmount, source)
+		if err != nil || amount <= 0 {
+			log.Errorw("Quote failed: Invalid source amount", "amount", req.SourceAmount, "error", err)
+			return model.FXQuoteResponse{}, &ServiceError{Message: "Invalid source amount", Code: http.StatusBadRequest, Error: err}
+		}
+		converted, err := rate.Convert(amount, source, target)
+		if err != nil {
+			log.Errorw("Quote failed: Converted amount out of range", "error", err)
+			return model.FXQuoteResponse{}, &ServiceError{Message: "Invalid source amount", Code: http.StatusBadRequest, Error: err}
+		}
+		response.SourceAmount = amount.Format(source)
+		response.TargetAmount = converted.Format(target)
+	}
+
+	if err := s.db.Create(&quote).Error; err != nil {
+		log.Errorw("Quote failed: Unable to store quote", "error", err)
+		return model.FXQuoteResponse{}, &ServiceError{Message: "Unable to create quote", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("FX quote created", "quote_id", quote.ID, "rate", quote.Rate, "expires_at", quote.ExpiresAt)
+
+	return response, nil
+}
@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"payment-service/internal/fx"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupFXTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	err = db.AutoMigrate(&model.Transfer{}, &model.Account{}, &model.FXRate{}, &model.FXQuote{}, &model.LedgerEntry{}, &model.TransferStatusHistory{}, &model.OutboxEvent{})
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db.Create(&model.Account{Name: "USD Account", Currency: "USD", Balance: money.Amount(100000)})
+	db.Create(&model.Account{Name: "EUR Account", Currency: "EUR", Balance: money.Amount(0)})
+	db.Create(&model.Account{Name: "JPY Account", Currency: "JPY", Balance: money.Amount(0)})
+	db.Create(&model.FXRate{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: "0.9231"})
+	db.Create(&model.FXRate{BaseCurrency: "USD", QuoteCurrency: "JPY", Rate: "151.37"})
+
+	return db
+}
+
+func TestCreateQuote_Success(t *testing.T) {
+	db := setupFXTestDB()
+	fxService := service.NewFXService(db, fx.NewDBProvider(db), time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	quote, err := fxService.CreateQuote(&model.FXQuoteRequest{SourceCurrency: "usd", TargetCurrency: "EUR", SourceAmount: "100.00"}, ctx)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, quote.QuoteID)
+	assert.Equal(t, "0.9231", quote.Rate)
+	assert.Equal(t, "100.00", quote.SourceAmount)
+	assert.Equal(t, "92.31", quote.TargetAmount)
+}
+
+func TestCreateQuote_RateNotAvailable(t *testing.T) {
+	db := setupFXTestDB()
+	fxService := service.NewFXService(db, fx.NewDBProvider(db), time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	_, err := fxService.CreateQuote(&model.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "USD"}, ctx)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+}
+
+func TestCreateTransfer_CrossCurrencyAtLockedRate(t *testing.T) {
+	db := setupFXTestDB()
+	fxService := service.NewFXService(db, fx.NewDBProvider(db), time.Minute)
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	quote, err := fxService.CreateQuote(&model.FXQuoteRequest{SourceCurrency: "USD", TargetCurrency: "JPY"}, ctx)
+	assert.Nil(t, err)
+
+	// A rate change after the quote must not affect the transfer.
+	db.Model(&model.FXRate{}).Where("quote_currency = ?", "JPY").Update("rate", "160")
+
+	req := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 3, Amount: "10.05", QuoteID: quote.QuoteID}
+	transfer, err := transferService.CreateTransfer(&req, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, money.Amount(1005), transfer.Amount)
+	assert.Equal(t, money.Amount(1521), transfer.DestinationAmount)
+	assert.Equal(t, "JPY", transfer.DestinationCurrency)
+	assert.Equal(t, "151.37", transfer.FXRate)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(transfer.ID), "COMPLETED", "", ctx)
+	assert.Nil(t, err)
+
+	var origin, destination model.Account
+	db.First(&origin, 1)
+	db.First(&destination, 3)
+	assert.Equal(t, money.Amount(98995), origin.Balance)
+	assert.Equal(t, money.Amount(1521), destination.Balance)
+
+	// Each currency balances through the FX position.
+	var entries []model.LedgerEntry
+	db.Where("transfer_id = ?", transfer.ID).Order("id").Find(&entries)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "FX_POSITION", entries[1].LedgerType)
+	assert.Equal(t, "USD", entries[1].Currency)
+	assert.Equal(t, "JPY", entries[2].Currency)
+}
+
+func TestCreateTransfer_QuoteCanOnlyBeUsedOnce(t *testing.T) {
+	db := setupFXTestDB()
+	fxService := service.NewFXService(db, fx.NewDBProvider(db), time.Minute)
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	quote, err := fxService.CreateQuote(&model.FXQuoteRequest{SourceCurrency: "USD", TargetCurrency: "EUR"}, ctx)
+	assert.Nil(t, err)
+
+	req := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", QuoteID: quote.QuoteID}
+	_, err = transferService.CreateTransfer(&req, ctx)
+	assert.Nil(t, err)
+
+	_, err = transferService.CreateTransfer(&req, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+}
+
+func TestCreateTransfer_QuoteExpired(t *testing.T) {
+	db := setupFXTestDB()
+	fxService := service.NewFXService(db, fx.NewDBProvider(db), -time.Second)
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	quote, err := fxService.CreateQuote(&model.FXQuoteRequest{SourceCurrency: "USD", TargetCurrency: "EUR"}, ctx)
+	assert.Nil(t, err)
+
+	req := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", QuoteID: quote.QuoteID}
+	_, err = transferService.CreateTransfer(&req, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Quote has expired", err.Message)
+}
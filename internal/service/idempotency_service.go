@@ -0,0 +1,112 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyService interface {
+	Begin(scope, key, requestHash string, ctx *gin.Context) (*model.IdempotencyKey, bool, *ServiceError)
+	Complete(record *model.IdempotencyKey, statusCode int, body []byte, ctx *gin.Context) *ServiceError
+	Abandon(record *model.IdempotencyKey, ctx *gin.Context)
+	CronPurgeExpiredKeys() *ServiceError
+}
+
+type idempotencyService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{db: db, ttl: ttl}
+}
+
+// Begin claims the key for this request. When the key was already used with
+// the same body and has a stored response, that record is returned with
+// replay set so the caller can send it back as-is.
+func (s *idempotencyService) Begin(scope, key, requestHash string, ctx *gin.Context) (*model.IdempotencyKey, bool, *ServiceError) {
+	log := logger.From(ctx)
+
+	now := time.Now()
+	if err := s.db.Where("scope = ? AND idempotency_key = ? AND expires_at < ?", scope, key, now).Delete(&model.IdempotencyKey{}).Error; err != nil {
+		log.Errorw("Failed to clear expired idempotency key", "key", key, "error", err)
+		return nil, false, &ServiceError{Message: "Failed to process idempotency key", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	record := model.IdempotencyKey{
+		Scope:        scope,
+		Key:          key,
+		RequestHash:  requestHash,
+		ResponseBody: []byte{},
+		ExpiresAt:    now.Add(s.ttl),
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		log.Errorw("Failed to store idempotency key", "key", key, "error", result.Error)
+		return nil, false, &ServiceError{Message: "Failed to process idempotency key", Code: http.StatusInternalServerError, Error: result.Error}
+	}
+	if result.RowsAffected == 1 {
+		return &record, false, nil
+	}
+
+	var existing model.IdempotencyKey
+	if err := s.db.Where("scope = ? AND idempotency_key = ?", scope, key).First(&existing).Error; err != nil {
+		log.Errorw("Failed to load idempotency key", "key", key, "error", err)
+		return nil, false, &ServiceError{Message: "Failed to process idempotency key", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if existing.RequestHash != requestHash {
+		log.Warnw("Idempotency key reused with a different request body", "key", key)
+		return nil, false, &ServiceError{Message: "Idempotency key was already used with a different request", Code: http.StatusUnprocessableEntity}
+	}
+
+	if existing.StatusCode == 0 {
+		log.Warnw("Idempotency key is still being processed", "key", key)
+		return nil, false, &ServiceError{Message: "A request with this idempotency key is still in progress", Code: http.StatusConflict}
+	}
+
+	log.Infow("Replaying idempotent response", "key", key, "status", existing.StatusCode)
+	return &existing, true, nil
+}
+
+func (s *idempotencyService) Complete(record *model.IdempotencyKey, statusCode int, body []byte, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	if err := s.db.Save(record).Error; err != nil {
+		log.Errorw("Failed to store idempotent response", "key", record.Key, "error", err)
+		return &ServiceError{Message: "Failed to store idempotent response", Code: http.StatusInternalServerError, Error: err}
+	}
+	return nil
+}
+
+// Abandon frees the key so that a retry is processed again, used when the
+// original request failed in a way the client should be able to retry.
+func (s *idempotencyService) Abandon(record *model.IdempotencyKey, ctx *gin.Context) {
+	log := logger.From(ctx)
+
+	if err := s.db.Delete(record).Error; err != nil {
+		log.Errorw("Failed to release idempotency key", "key", record.Key, "error", err)
+	}
+}
+
+func (s *idempotencyService) CronPurgeExpiredKeys() *ServiceError {
+	result := s.db.Where("expires_at < ?", time.Now()).Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		return &ServiceError{Message: "Failed to purge idempotency keys", Code: http.StatusInternalServerError, Error: result.Error}
+	}
+
+	if result.RowsAffected > 0 {
+		log.Println("[Cron] Purged idempotency keys", "count", result.RowsAffected)
+	}
+
+	return nil
+}
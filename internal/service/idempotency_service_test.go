@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"log"
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIdempotencyTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	err = db.AutoMigrate(&model.IdempotencyKey{})
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	db := setupIdempotencyTestDB()
+	idempotencyService := service.NewIdempotencyService(db, time.Hour)
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	record, replay, err := idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.Nil(t, err)
+	assert.False(t, replay)
+	assert.Nil(t, idempotencyService.Complete(record, http.StatusCreated, []byte(`{"id":1}`), ctx))
+
+	stored, replay, err := idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.Nil(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, http.StatusCreated, stored.StatusCode)
+	assert.Equal(t, `{"id":1}`, string(stored.ResponseBody))
+}
+
+func TestIdempotency_DifferentBodyIsRejected(t *testing.T) {
+	db := setupIdempotencyTestDB()
+	idempotencyService := service.NewIdempotencyService(db, time.Hour)
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	record, _, err := idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, idempotencyService.Complete(record, http.StatusCreated, []byte(`{}`), ctx))
+
+	_, _, err = idempotencyService.Begin("1", "key-1", "hash-b", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+}
+
+func TestIdempotency_InProgressAndScope(t *testing.T) {
+	db := setupIdempotencyTestDB()
+	idempotencyService := service.NewIdempotencyService(db, time.Hour)
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	_, _, err := idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.Nil(t, err)
+
+	_, _, err = idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+
+	// The same key belongs to a different account's scope.
+	_, replay, err := idempotencyService.Begin("2", "key-1", "hash-b", ctx)
+	assert.Nil(t, err)
+	assert.False(t, replay)
+}
+
+func TestIdempotency_ExpiredKeysArePurged(t *testing.T) {
+	db := setupIdempotencyTestDB()
+	idempotencyService := service.NewIdempotencyService(db, -time.Minute)
+
+	ctx := &gin.Context{}
+	logger.Init("test")
+
+	record, _, err := idempotencyService.Begin("1", "key-1", "hash-a", ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, idempotencyService.Complete(record, http.StatusCreated, []byte(`{}`), ctx))
+
+	// An expired key is treated as new rather than replayed.
+	_, replay, err := idempotencyService.Begin("1", "key-1", "hash-b", ctx)
+	assert.Nil(t, err)
+	assert.False(t, replay)
+
+	assert.Nil(t, idempotencyService.CronPurgeExpiredKeys())
+	var count int64
+	db.Model(&model.IdempotencyKey{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,520 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/webhook"
+	"payment-service/internal/model"
+	"payment-service/internal/outbox"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	webhookSecretPrefix = "whsec_"
+	webhookTimeout      = 10 * time.Second
+	// webhookClaimTTL keeps a delivery from being picked up again while an
+	// attempt is in flight.
+	webhookClaimTTL    = 2 * webhookTimeout
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookBatchSize   = 100
+	webhookLogLimit    = 100
+
+	HeaderWebhookID    = "X-Webhook-Id"
+	HeaderWebhookEvent = "X-Webhook-Event"
+)
+
+type MerchantWebhookService interface {
+	CreateEndpoint(accountID string, req *model.WebhookEndpointRequest, ctx *gin.Context) (model.WebhookEndpointResponse, *ServiceError)
+	ListEndpoints(accountID string, ctx *gin.Context) ([]model.WebhookEndpointResponse, *ServiceError)
+	DeleteEndpoint(accountID string, endpointID string, ctx *gin.Context) *ServiceError
+	ListEndpointDeliveries(accountID string, endpointID string, ctx *gin.Context) ([]model.WebhookDeliveryResponse, *ServiceError)
+	ListDeliveries(status string, ctx *gin.Context) ([]model.WebhookDeliveryResponse, *ServiceError)
+	Redeliver(deliveryID string, ctx *gin.Context) (model.WebhookDeliveryResponse, *ServiceError)
+	HandleEvent(ctx context.Context, event outbox.Event) error
+	CronDeliverWebhooks(jobCtx context.Context) *ServiceError
+}
+
+type merchantWebhookService struct {
+	db          *gorm.DB
+	client      *http.Client
+	maxAttempts int
+	development bool
+}
+
+// NewMerchantWebhookService sends each event up to maxAttempts times before
+// the delivery is dead-lettered. Endpoints must be https and public, except
+// in development, where plain http and local addresses are allowed too.
+func NewMerchantWebhookService(db *gorm.DB, maxAttempts int, development bool) MerchantWebhookService {
+	client := &http.Client{
+		Timeout: webhookTimeout,
+		// A redirect is a misconfigured endpoint, not a delivery.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if !development {
+		client.Transport = webhookTransport()
+	}
+
+	return &merchantWebhookService{
+		db:          db,
+		client:      client,
+		maxAttempts: maxAttempts,
+		development: development,
+	}
+}
+
+// CreateEndpoint registers a callback URL for the account's events. The
+// signing secret is only returned here.
+func (s *merchantWebhookService) CreateEndpoint(accountID string, req *model.WebhookEndpointRequest, ctx *gin.Context) (model.WebhookEndpointResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	if serr := authorizeWebhooks(accountID, true, ctx); serr != nil {
+		return model.WebhookEndpointResponse{}, serr
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || target.Hostname() == "" ||
+		(target.Scheme != "https" && !(s.development && target.Scheme == "http")) {
+		return model.WebhookEndpointResponse{}, &ServiceError{Message: "URL must be an absolute https URL", Code: http.StatusBadRequest}
+	}
+	if !s.development {
+		if err := checkWebhookHost(target.Hostname()); err != nil {
+			log.Warnw("Rejected webhook endpoint", "account_id", accountID, "host", target.Hostname(), "error", err)
+			if errors.Is(err, errWebhookAddress) {
+				return model.WebhookEndpointResponse{}, &ServiceError{Message: "URL must not point at a private or local address", Code: http.StatusBadRequest, Error: err}
+			}
+			return model.WebhookEndpointResponse{}, &ServiceError{Message: "URL host could not be resolved", Code: http.StatusBadRequest, Error: err}
+		}
+	}
+
+	var eventTypes []string
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(constant.WebhookEventTypes, eventType) {
+			return model.WebhookEndpointResponse{}, &ServiceError{Message: fmt.Sprintf("Unknown event type %q", eventType), Code: http.StatusBadRequest}
+		}
+		if !slices.Contains(eventTypes, eventType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	if len(eventTypes) == 0 {
+		return model.WebhookEndpointResponse{}, &ServiceError{Message: "At least one event type is required", Code: http.StatusBadRequest}
+	}
+
+	var account model.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		return model.WebhookEndpointResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound, Error: err}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Errorw("Failed to create webhook endpoint", "error", err)
+		return model.WebhookEndpointResponse{}, &ServiceError{Message: "Failed to create webhook endpoint", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	endpoint := model.WebhookEndpoint{
+		AccountID:  account.ID,
+		URL:        target.String(),
+		EventTypes: strings.Join(eventTypes, " "),
+		Secret:     webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(raw),
+	}
+	if err := s.db.Create(&endpoint).Error; err != nil {
+		log.Errorw("Failed to store webhook endpoint", "account_id", account.ID, "error", err)
+		return model.WebhookEndpointResponse{}, &ServiceError{Message: "Failed to create webhook endpoint", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Created webhook endpoint", "endpoint_id", endpoint.ID, "account_id", account.ID, "event_types", endpoint.EventTypes)
+	response := endpoint.ToResponse()
+	response.Secret = endpoint.Secret
+	return response, nil
+}
+
+// ListEndpoints returns the account's endpoints, disabled ones included.
+func (s *merchantWebhookService) ListEndpoints(accountID string, ctx *gin.Context) ([]model.WebhookEndpointResponse, *ServiceError) {
+	if serr := authorizeWebhooks(accountID, false, ctx); serr != nil {
+		return nil, serr
+	}
+
+	var endpoints []model.WebhookEndpoint
+	if err := s.db.Where("account_id = ?", accountID).Order("id").Find(&endpoints).Error; err != nil {
+		logger.From(ctx).Errorw("Failed to list webhook endpoints", "account_id", accountID, "error", err)
+		return nil, &ServiceError{Message: "Failed to list webhook endpoints", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	responses := make([]model.WebhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		responses = append(responses, endpoint.ToResponse())
+	}
+	return responses, nil
+}
+
+// DeleteEndpoint disables the endpoint. It stays listed with its delivery
+// log, and deliveries still pending for it are dead-lettered when they come
+// due.
+func (s *merchantWebhookService) DeleteEndpoint(accountID string, endpointID string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	if serr := authorizeWebhooks(accountID, true, ctx); serr != nil {
+		return serr
+	}
+
+	endpoint, serr := s.findEndpoint(accountID, endpointID)
+	if serr != nil {
+		return serr
+	}
+	if endpoint.DisabledAt != nil {
+		return nil
+	}
+
+	if err := s.db.Model(&endpoint).Update("disabled_at", time.Now().UTC()).Error; err != nil {
+		log.Errorw("Failed to disable webhook endpoint", "endpoint_id", endpoint.ID, "error", err)
+		return &ServiceError{Message: "Failed to delete webhook endpoint", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Disabled webhook endpoint", "endpoint_id", endpoint.ID, "account_id", endpoint.AccountID)
+	return nil
+}
+
+// ListEndpointDeliveries returns the endpoint's latest deliveries, newest
+// first, each with its attempts.
+func (s *merchantWebhookService) ListEndpointDeliveries(accountID string, endpointID string, ctx *gin.Context) ([]model.WebhookDeliveryResponse, *ServiceError) {
+	if serr := authorizeWebhooks(accountID, false, ctx); serr != nil {
+		return nil, serr
+	}
+
+	endpoint, serr := s.findEndpoint(accountID, endpointID)
+	if serr != nil {
+		return nil, serr
+	}
+
+	return s.deliveryLog(s.db.Where("endpoint_id = ?", endpoint.ID), ctx)
+}
+
+// ListDeliveries returns the latest deliveries of every endpoint, newest
+// first, optionally only those in one status, e.g. DEAD.
+func (s *merchantWebhookService) ListDeliveries(status string, ctx *gin.Context) ([]model.WebhookDeliveryResponse, *ServiceError) {
+	query := s.db
+	if status != "" {
+		status = strings.ToUpper(status)
+		if status != constant.WebhookDeliveryPending && status != constant.WebhookDeliverySucceeded && status != constant.WebhookDeliveryDead {
+			return nil, &ServiceError{Message: "Invalid status", Code: http.StatusBadRequest}
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	return s.deliveryLog(query, ctx)
+}
+
+// Redeliver sends a delivery again right away, whatever its status, and
+// gives it a fresh set of attempts if that fails.
+func (s *merchantWebhookService) Redeliver(deliveryID string, ctx *gin.Context) (model.WebhookDeliveryResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	var delivery model.WebhookDelivery
+	if err := s.db.First(&delivery, "id = ?", deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Delivery not found", Code: http.StatusNotFound, Error: err}
+		}
+		log.Errorw("Failed to load webhook delivery", "delivery_id", deliveryID, "error", err)
+		return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Failed to redeliver webhook", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	var endpoint model.WebhookEndpoint
+	if err := s.db.First(&endpoint, delivery.EndpointID).Error; err != nil {
+		log.Errorw("Failed to load webhook endpoint", "endpoint_id", delivery.EndpointID, "error", err)
+		return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Failed to redeliver webhook", Code: http.StatusInternalServerError, Error: err}
+	}
+	if endpoint.DisabledAt != nil {
+		return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Webhook endpoint is disabled", Code: http.StatusConflict}
+	}
+
+	// Claimed like a scheduled attempt, so the cron doesn't send it too.
+	delivery.Status = constant.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC().Add(webhookClaimTTL)
+	if err := s.db.Save(&delivery).Error; err != nil {
+		log.Errorw("Failed to reset webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Failed to redeliver webhook", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if err := s.attempt(&delivery, &endpoint); err != nil {
+		log.Errorw("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return model.WebhookDeliveryResponse{}, &ServiceError{Message: "Failed to redeliver webhook", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Redelivered webhook", "delivery_id", delivery.ID, "status", delivery.Status, "response_code", delivery.LastResponseCode, "caller", actorFrom(ctx))
+
+	var attempts []model.WebhookDeliveryAttempt
+	s.db.Where("delivery_id = ?", delivery.ID).Order("id DESC").Limit(webhookLogLimit).Find(&attempts)
+	return delivery.ToResponse(attempts), nil
+}
+
+// HandleEvent queues the event for every endpoint of the accounts on either
+// side of the transfer that subscribed to it. It is an outbox handler, so the
+// same event may arrive more than once; it is only queued once per endpoint.
+func (s *merchantWebhookService) HandleEvent(ctx context.Context, event outbox.Event) error {
+	if !slices.Contains(constant.WebhookEventTypes, event.Type) {
+		return nil
+	}
+
+	var transfer model.TransferResponse
+	if err := json.Unmarshal(event.Data, &transfer); err != nil {
+		// Retrying won't make it parse; don't hold up the outbox for it.
+		log.Println("[WEBHOOK] Skipping unreadable event", event.ID, ":", err)
+		return nil
+	}
+
+	var endpoints []model.WebhookEndpoint
+	err := s.db.WithContext(ctx).
+		Where("account_id IN ? AND disabled_at IS NULL", []uint{transfer.OriginAccountID, transfer.DestinationAccountID}).
+		Find(&endpoints).Error
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var deliveries []model.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, model.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        constant.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// CronDeliverWebhooks makes one attempt at every delivery that is due. Once
+// jobCtx is done it stops taking new ones; the rest stay due.
+func (s *merchantWebhookService) CronDeliverWebhooks(jobCtx context.Context) *ServiceError {
+	now := time.Now().UTC()
+
+	var due []model.WebhookDelivery
+	err := s.db.Where("status = ? AND next_attempt_at <= ?", constant.WebhookDeliveryPending, now).
+		Order("id").
+		Limit(webhookBatchSize).
+		Find(&due).Error
+	if err != nil {
+		return &ServiceError{Message: "Failed to deliver webhooks", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	delivered := 0
+	for i := range due {
+		if jobCtx.Err() != nil {
+			break
+		}
+		delivery := &due[i]
+
+		// Another instance, or an earlier run still sending, may have it.
+		result := s.db.Model(&model.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, constant.WebhookDeliveryPending, now).
+			Update("next_attempt_at", time.Now().UTC().Add(webhookClaimTTL))
+		if result.Error != nil {
+			return &ServiceError{Message: "Failed to deliver webhooks", Code: http.StatusInternalServerError, Error: result.Error}
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		var endpoint model.WebhookEndpoint
+		if err := s.db.First(&endpoint, delivery.EndpointID).Error; err != nil {
+			return &ServiceError{Message: "Failed to deliver webhooks", Code: http.StatusInternalServerError, Error: err}
+		}
+		if err := s.attempt(delivery, &endpoint); err != nil {
+			return &ServiceError{Message: "Failed to deliver webhooks", Code: http.StatusInternalServerError, Error: err}
+		}
+		if delivery.Status == constant.WebhookDeliverySucceeded {
+			delivered++
+		}
+	}
+
+	if len(due) > 0 {
+		log.Println("[Cron] Delivered webhooks", "delivered", delivered, "due", len(due))
+	}
+
+	return nil
+}
+
+// attempt sends the delivery once and records the outcome: delivered on a
+// 2xx, otherwise retried with exponential backoff until it runs out of
+// attempts and is dead-lettered.
+func (s *merchantWebhookService) attempt(delivery *model.WebhookDelivery, endpoint *model.WebhookEndpoint) error {
+	now := time.Now().UTC()
+	record := model.WebhookDeliveryAttempt{DeliveryID: delivery.ID}
+
+	if endpoint.DisabledAt != nil {
+		delivery.Status = constant.WebhookDeliveryDead
+		delivery.LastError = "Endpoint disabled"
+		return s.db.Save(delivery).Error
+	}
+
+	code, err := s.send(endpoint, delivery)
+	record.ResponseCode = code
+	record.DurationMs = time.Since(now).Milliseconds()
+	if err == nil && (code < 200 || code > 299) {
+		err = fmt.Errorf("HTTP %d", code)
+	}
+	if err != nil {
+		record.Error = clip(err.Error(), 255)
+	}
+
+	delivery.Attempts++
+	delivery.LastResponseCode = code
+	delivery.LastError = record.Error
+	switch {
+	case err == nil:
+		delivery.Status = constant.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.maxAttempts:
+		delivery.Status = constant.WebhookDeliveryDead
+	default:
+		delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Save(delivery).Error
+	})
+}
+
+// send POSTs the payload signed the same way provider webhooks are: an
+// HMAC-SHA256 of "<timestamp>.<body>" with the endpoint's secret.
+func (s *merchantWebhookService) send(endpoint *model.WebhookEndpoint, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderTimestamp, fmt.Sprint(timestamp))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(endpoint.Secret, timestamp, body))
+	req.Header.Set(HeaderWebhookID, delivery.EventID)
+	req.Header.Set(HeaderWebhookEvent, delivery.EventType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
+
+func (s *merchantWebhookService) findEndpoint(accountID string, endpointID string) (model.WebhookEndpoint, *ServiceError) {
+	var endpoint model.WebhookEndpoint
+	if err := s.db.Where("id = ? AND account_id = ?", endpointID, accountID).First(&endpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.WebhookEndpoint{}, &ServiceError{Message: "Webhook endpoint not found", Code: http.StatusNotFound, Error: err}
+		}
+		return model.WebhookEndpoint{}, &ServiceError{Message: "Failed to retrieve webhook endpoint", Code: http.StatusInternalServerError, Error: err}
+	}
+	return endpoint, nil
+}
+
+// deliveryLog returns the latest deliveries matching query with their
+// attempts, newest first.
+func (s *merchantWebhookService) deliveryLog(query *gorm.DB, ctx *gin.Context) ([]model.WebhookDeliveryResponse, *ServiceError) {
+	var deliveries []model.WebhookDelivery
+	if err := query.Order("id DESC").Limit(webhookLogLimit).Find(&deliveries).Error; err != nil {
+		logger.From(ctx).Errorw("Failed to list webhook deliveries", "error", err)
+		return nil, &ServiceError{Message: "Failed to list webhook deliveries", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	var attempts []model.WebhookDeliveryAttempt
+	if len(ids) > 0 {
+		if err := s.db.Where("delivery_id IN ?", ids).Order("id DESC").Find(&attempts).Error; err != nil {
+			logger.From(ctx).Errorw("Failed to list webhook delivery attempts", "error", err)
+			return nil, &ServiceError{Message: "Failed to list webhook deliveries", Code: http.StatusInternalServerError, Error: err}
+		}
+	}
+	byDelivery := map[uint][]model.WebhookDeliveryAttempt{}
+	for _, attempt := range attempts {
+		byDelivery[attempt.DeliveryID] = append(byDelivery[attempt.DeliveryID], attempt)
+	}
+
+	responses := make([]model.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		responses = append(responses, delivery.ToResponse(byDelivery[delivery.ID]))
+	}
+	return responses, nil
+}
+
+// authorizeWebhooks lets account owners manage their own endpoints, and
+// staff read or manage any account's.
+func authorizeWebhooks(accountID string, write bool, ctx *gin.Context) *ServiceError {
+	principal, _ := auth.PrincipalFrom(ctx)
+	if write {
+		if principal.HasScope(constant.ScopeAccountsWriteAny) ||
+			(auth.OwnsAccount(ctx, accountID) && principal.HasScope(constant.ScopeTransfersWrite)) {
+			return nil
+		}
+	} else if principal.HasScope(constant.ScopeAccountsReadAny) || auth.OwnsAccount(ctx, accountID) {
+		return nil
+	}
+
+	logger.From(ctx).Warnw("Forbidden webhook endpoint request", "account_id", accountID, "caller", principal.Subject)
+	return &ServiceError{Message: "Forbidden", Code: http.StatusForbidden}
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
+
+// clip cuts s to at most n bytes without splitting a UTF-8 sequence.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,344 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/middleware/webhook"
+	"payment-service/internal/model"
+	"payment-service/internal/outbox"
+	"payment-service/internal/service"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupWebhookTestDB() *gorm.DB {
+	db := setupTransferTestDB()
+	if err := db.AutoMigrate(&model.WebhookEndpoint{}, &model.WebhookDelivery{}, &model.WebhookDeliveryAttempt{}); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// customerContext is authedContext with the scopes of a customer token.
+func customerContext(accountID uint) *gin.Context {
+	ctx := &gin.Context{}
+	auth.SetPrincipal(ctx, auth.Principal{AccountID: fmt.Sprint(accountID), Subject: fmt.Sprint(accountID), Scopes: constant.RoleScopes[constant.RoleCustomer]})
+	return ctx
+}
+
+func adminContext() *gin.Context {
+	ctx := &gin.Context{}
+	auth.SetPrincipal(ctx, auth.Principal{Subject: "staff:root", Scopes: []string{constant.ScopeAdmin}})
+	return ctx
+}
+
+// merchantServer stands in for a merchant endpoint, answering with status
+// and recording what it receives.
+type merchantServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newMerchantServer(t *testing.T, status int) *merchantServer {
+	m := &merchantServer{status: status}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requests = append(m.requests, r)
+		m.bodies = append(m.bodies, body)
+		w.WriteHeader(m.status)
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *merchantServer) setStatus(status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+}
+
+// relayTo hands every outbox event to the webhook service, as the relay
+// does. (The relay itself holds a transaction open while it publishes, which
+// an in-memory SQLite database can't share with the handler.)
+func relayTo(t *testing.T, db *gorm.DB, webhookService service.MerchantWebhookService) {
+	var records []model.OutboxEvent
+	db.Order("id").Find(&records)
+	for _, record := range records {
+		event := outbox.Event{ID: record.EventID, Type: record.Type, AggregateID: record.AggregateID, OccurredAt: record.CreatedAt, Data: json.RawMessage(record.Payload)}
+		assert.NoError(t, webhookService.HandleEvent(t.Context(), event))
+	}
+}
+
+func makeDeliveriesDue(db *gorm.DB) {
+	db.Model(&model.WebhookDelivery{}).Where("status = ?", constant.WebhookDeliveryPending).
+		Update("next_attempt_at", time.Now().UTC().Add(-time.Second))
+}
+
+func TestCreateEndpoint_Validation(t *testing.T) {
+	db := setupWebhookTestDB()
+	webhookService := service.NewMerchantWebhookService(db, 3, true)
+	logger.Init("test")
+
+	_, err := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "ftp://example.com", EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "https://example.com/hooks", EventTypes: []string{"account.created"}}, customerContext(2))
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "https://example.com/hooks", EventTypes: []string{constant.EventTransferCompleted}}, customerContext(1))
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	// Read-only callers can't register endpoints for their own account.
+	_, err = webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "https://example.com/hooks", EventTypes: []string{constant.EventTransferCompleted}}, authedContext(2))
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	endpoint, err := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "https://example.com/hooks", EventTypes: []string{constant.EventTransferCompleted, constant.EventTransferCompleted}}, customerContext(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{constant.EventTransferCompleted}, endpoint.EventTypes)
+	assert.Regexp(t, "^whsec_", endpoint.Secret)
+
+	listed, err := webhookService.ListEndpoints("2", customerContext(2))
+	assert.Nil(t, err)
+	assert.Len(t, listed, 1)
+	assert.Empty(t, listed[0].Secret)
+}
+
+func TestCronDeliverWebhooks_SignedDelivery(t *testing.T) {
+	db := setupWebhookTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	webhookService := service.NewMerchantWebhookService(db, 3, true)
+	logger.Init("test")
+
+	merchant := newMerchantServer(t, http.StatusOK)
+	endpoint, serr := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: merchant.URL, EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+	assert.Nil(t, serr)
+
+	transfer := completedTransfer(t, transferService, "12.00")
+
+	// Relaying twice, as after a broker failure, queues the event once.
+	relayTo(t, db, webhookService)
+	relayTo(t, db, webhookService)
+
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+
+	assert.Len(t, merchant.requests, 1)
+	request, body := merchant.requests[0], merchant.bodies[0]
+	assert.Equal(t, constant.EventTransferCompleted, request.Header.Get(service.HeaderWebhookEvent))
+	timestamp, _ := strconv.ParseInt(request.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	assert.Equal(t, webhook.Sign(endpoint.Secret, timestamp, body), request.Header.Get(webhook.HeaderSignature))
+
+	var event outbox.Event
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, request.Header.Get(service.HeaderWebhookID), event.ID)
+	assert.Equal(t, transfer.ID, event.AggregateID)
+	var payload model.TransferResponse
+	assert.NoError(t, json.Unmarshal(event.Data, &payload))
+	assert.Equal(t, "COMPLETED", payload.Status)
+
+	deliveries, serr := webhookService.ListEndpointDeliveries("2", fmt.Sprint(endpoint.ID), customerContext(2))
+	assert.Nil(t, serr)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, constant.WebhookDeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].LastResponseCode)
+	assert.Len(t, deliveries[0].Log, 1)
+
+	// Nothing is sent twice.
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+	assert.Len(t, merchant.requests, 1)
+
+	// The sender's account has no endpoint, so it gets nothing.
+	_, serr = webhookService.ListEndpointDeliveries("1", fmt.Sprint(endpoint.ID), customerContext(1))
+	assert.Equal(t, http.StatusNotFound, serr.Code)
+}
+
+func TestCronDeliverWebhooks_RetriesThenDeadLetters(t *testing.T) {
+	db := setupWebhookTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	webhookService := service.NewMerchantWebhookService(db, 3, true)
+	logger.Init("test")
+
+	merchant := newMerchantServer(t, http.StatusInternalServerError)
+	_, serr := webhookService.CreateEndpoint("1", &model.WebhookEndpointRequest{URL: merchant.URL, EventTypes: []string{constant.EventTransferCreated}}, customerContext(1))
+	assert.Nil(t, serr)
+
+	_, serr = transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "5.00"}, authedContext(1))
+	assert.Nil(t, serr)
+	relayTo(t, db, webhookService)
+
+	var previousWait time.Duration
+	for attempt := 1; attempt <= 3; attempt++ {
+		makeDeliveriesDue(db)
+		assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+
+		var delivery model.WebhookDelivery
+		db.First(&delivery)
+		assert.Equal(t, attempt, delivery.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, delivery.LastResponseCode)
+		if attempt < 3 {
+			assert.Equal(t, constant.WebhookDeliveryPending, delivery.Status)
+			wait := time.Until(delivery.NextAttemptAt)
+			assert.Greater(t, wait, previousWait)
+			previousWait = wait
+		} else {
+			assert.Equal(t, constant.WebhookDeliveryDead, delivery.Status)
+		}
+	}
+
+	// Dead letters aren't retried.
+	makeDeliveriesDue(db)
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+	assert.Len(t, merchant.requests, 3)
+
+	dead, serr := webhookService.ListDeliveries("dead", adminContext())
+	assert.Nil(t, serr)
+	assert.Len(t, dead, 1)
+	assert.Len(t, dead[0].Log, 3)
+	assert.Equal(t, "HTTP 500", dead[0].Log[0].Error)
+
+	merchant.setStatus(http.StatusNoContent)
+	redelivered, serr := webhookService.Redeliver(fmt.Sprint(dead[0].ID), adminContext())
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.WebhookDeliverySucceeded, redelivered.Status)
+	assert.Equal(t, http.StatusNoContent, redelivered.LastResponseCode)
+	assert.Len(t, redelivered.Log, 4)
+	assert.Len(t, merchant.requests, 4)
+
+	_, serr = webhookService.Redeliver("999", adminContext())
+	assert.Equal(t, http.StatusNotFound, serr.Code)
+}
+
+func TestCronDeliverWebhooks_StopsWhenCancelled(t *testing.T) {
+	db := setupWebhookTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	webhookService := service.NewMerchantWebhookService(db, 3, true)
+	logger.Init("test")
+
+	merchant := newMerchantServer(t, http.StatusOK)
+	_, serr := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: merchant.URL, EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+	assert.Nil(t, serr)
+	completedTransfer(t, transferService, "12.00")
+	relayTo(t, db, webhookService)
+
+	// As when this instance loses leadership: nothing more is sent, and
+	// the delivery stays due for the new leader.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	assert.Nil(t, webhookService.CronDeliverWebhooks(ctx))
+	assert.Empty(t, merchant.requests)
+
+	var delivery model.WebhookDelivery
+	db.First(&delivery)
+	assert.Equal(t, constant.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.False(t, delivery.NextAttemptAt.After(time.Now()))
+
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+	assert.Len(t, merchant.requests, 1)
+}
+
+func TestDeleteEndpoint_StopsDeliveries(t *testing.T) {
+	db := setupWebhookTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	webhookService := service.NewMerchantWebhookService(db, 3, true)
+	logger.Init("test")
+
+	merchant := newMerchantServer(t, http.StatusOK)
+	endpoint, serr := webhookService.CreateEndpoint("1", &model.WebhookEndpointRequest{URL: merchant.URL, EventTypes: []string{constant.EventTransferCreated}}, customerContext(1))
+	assert.Nil(t, serr)
+
+	_, serr = transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "5.00"}, authedContext(1))
+	assert.Nil(t, serr)
+	relayTo(t, db, webhookService)
+
+	assert.Equal(t, http.StatusForbidden, webhookService.DeleteEndpoint("1", fmt.Sprint(endpoint.ID), customerContext(2)).Code)
+	assert.Nil(t, webhookService.DeleteEndpoint("1", fmt.Sprint(endpoint.ID), customerContext(1)))
+
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+	assert.Empty(t, merchant.requests)
+
+	deliveries, serr := webhookService.ListEndpointDeliveries("1", fmt.Sprint(endpoint.ID), customerContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.WebhookDeliveryDead, deliveries[0].Status)
+	assert.Equal(t, "Endpoint disabled", deliveries[0].LastError)
+
+	_, serr = webhookService.Redeliver(fmt.Sprint(deliveries[0].ID), adminContext())
+	assert.Equal(t, http.StatusConflict, serr.Code)
+
+	// Events after the endpoint was deleted aren't queued for it.
+	_, serr = transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "5.00"}, authedContext(1))
+	assert.Nil(t, serr)
+	relayTo(t, db, webhookService)
+	var count int64
+	db.Model(&model.WebhookDelivery{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestCreateEndpoint_RejectsInternalAddresses(t *testing.T) {
+	db := setupWebhookTestDB()
+	webhookService := service.NewMerchantWebhookService(db, 3, false)
+	logger.Init("test")
+
+	for _, url := range []string{
+		"http://203.0.113.10/hooks",
+		"https://127.0.0.1/hooks",
+		"https://localhost:8443/hooks",
+		"https://10.1.2.3/hooks",
+		"https://192.168.0.10/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://0.0.0.0/hooks",
+		"https://[::1]/hooks",
+		"https://[fe80::1]/hooks",
+	} {
+		_, err := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: url, EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+		if assert.NotNil(t, err, url) {
+			assert.Equal(t, http.StatusBadRequest, err.Code, url)
+		}
+	}
+
+	endpoint, err := webhookService.CreateEndpoint("2", &model.WebhookEndpointRequest{URL: "https://203.0.113.10/hooks", EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://203.0.113.10/hooks", endpoint.URL)
+}
+
+func TestCronDeliverWebhooks_RefusesInternalAddresses(t *testing.T) {
+	db := setupWebhookTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	// Registered where local addresses are allowed, as if the host had
+	// resolved to a public address then and been repointed since.
+	merchant := newMerchantServer(t, http.StatusOK)
+	_, serr := service.NewMerchantWebhookService(db, 3, true).CreateEndpoint("2", &model.WebhookEndpointRequest{URL: merchant.URL, EventTypes: []string{constant.EventTransferCompleted}}, customerContext(2))
+	assert.Nil(t, serr)
+
+	webhookService := service.NewMerchantWebhookService(db, 3, false)
+	completedTransfer(t, transferService, "12.00")
+	relayTo(t, db, webhookService)
+
+	assert.Nil(t, webhookService.CronDeliverWebhooks(t.Context()))
+	assert.Empty(t, merchant.requests)
+
+	var delivery model.WebhookDelivery
+	db.First(&delivery)
+	assert.Equal(t, constant.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.LastError, "address not allowed")
+}
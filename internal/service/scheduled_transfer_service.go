@@ -0,0 +1,425 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxScheduleExecutions bounds the run history returned with a schedule.
+const maxScheduleExecutions = 100
+
+type ScheduledTransferService interface {
+	CreateSchedule(req *model.ScheduledTransferRequest, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError)
+	ListSchedules(accountID string, ctx *gin.Context) ([]model.ScheduledTransferResponse, *ServiceError)
+	GetSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError)
+	PauseSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError)
+	ResumeSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError)
+	CancelSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError)
+	CronRunDueSchedules() *ServiceError
+}
+
+type scheduledTransferService struct {
+	db        *gorm.DB
+	transfers TransferService
+}
+
+// NewScheduledTransferService creates the service; due schedules are turned
+// into transfers through transfers.CreateTransfer, so they get the same
+// checks and holds as any other transfer.
+func NewScheduledTransferService(db *gorm.DB, transfers TransferService) ScheduledTransferService {
+	return &scheduledTransferService{db: db, transfers: transfers}
+}
+
+// CreateSchedule validates and stores a schedule for the caller's account.
+// Its transfers are created later without an FX quote, so both accounts must
+// share a currency.
+func (s *scheduledTransferService) CreateSchedule(req *model.ScheduledTransferRequest, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	if !auth.OwnsAccount(ctx, fmt.Sprint(req.OriginAccountID)) {
+		log.Warnw("Schedule failed: Caller does not own the origin account", "origin_account_id", req.OriginAccountID, "caller", auth.AccountID(ctx))
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Origin account does not belong to the caller", Code: http.StatusForbidden}
+	}
+
+	if req.OriginAccountID == req.DestinationAccountID {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Cannot transfer to the same account", Code: http.StatusBadRequest}
+	}
+
+	now := time.Now().UTC()
+	runAt := req.RunAt.UTC()
+	if !runAt.After(now) {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "run_at must be in the future", Code: http.StatusBadRequest}
+	}
+
+	frequency := strings.ToUpper(req.Frequency)
+	switch frequency {
+	case "", constant.ScheduleFrequencyDaily, constant.ScheduleFrequencyWeekly, constant.ScheduleFrequencyMonthly:
+	default:
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "frequency must be DAILY, WEEKLY or MONTHLY", Code: http.StatusBadRequest}
+	}
+	if frequency == "" && (req.EndDate != nil || req.Count != 0) {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "end_date and count need a frequency", Code: http.StatusBadRequest}
+	}
+	if req.Count < 0 {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "count must be positive", Code: http.StatusBadRequest}
+	}
+
+	var endDate *time.Time
+	if req.EndDate != nil {
+		end := req.EndDate.UTC()
+		if end.Before(runAt) {
+			return model.ScheduledTransferResponse{}, &ServiceError{Message: "end_date must not be before run_at", Code: http.StatusBadRequest}
+		}
+		endDate = &end
+	}
+
+	var origin, destination model.Account
+	if err := s.db.First(&origin, req.OriginAccountID).Error; err != nil {
+		log.Warnw("Schedule failed: Origin account not found", "error", err)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Origin account not found", Code: http.StatusNotFound}
+	}
+	if err := s.db.First(&destination, req.DestinationAccountID).Error; err != nil {
+		log.Warnw("Schedule failed: Destination account not found", "error", err)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Destination account not found", Code: http.StatusNotFound}
+	}
+
+	if serr := requireActiveAccounts(&origin, &destination, ctx); serr != nil {
+		return model.ScheduledTransferResponse{}, serr
+	}
+
+	if origin.Currency != destination.Currency {
+		log.Warnw("Schedule failed: Accounts use different currencies", "origin_currency", origin.Currency, "destination_currency", destination.Currency)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Scheduled transfers need both accounts in the same currency", Code: http.StatusUnprocessableEntity}
+	}
+
+	amount, err := money.Parse(req.Amount.String(), money.CurrencyFor(origin.Currency))
+	if err != nil {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Invalid transfer amount: " + err.Error(), Code: http.StatusBadRequest, Error: err}
+	}
+	if amount <= 0 {
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Invalid transfer amount", Code: http.StatusBadRequest}
+	}
+
+	schedule := model.ScheduledTransfer{
+		OriginAccountID:      origin.ID,
+		DestinationAccountID: destination.ID,
+		Amount:               amount,
+		Currency:             origin.Currency,
+		RunAt:                runAt,
+		Frequency:            frequency,
+		EndDate:              endDate,
+		MaxRuns:              req.Count,
+		NextRunAt:            &runAt,
+		Status:               constant.ScheduleStatusActive,
+	}
+	if err := s.db.Create(&schedule).Error; err != nil {
+		log.Errorw("Schedule failed: Unable to create schedule", "error", err)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Unable to create schedule", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Scheduled transfer created", "schedule_id", schedule.ID, "run_at", runAt, "frequency", frequency)
+	return schedule.ToResponse(), nil
+}
+
+func (s *scheduledTransferService) ListSchedules(accountID string, ctx *gin.Context) ([]model.ScheduledTransferResponse, *ServiceError) {
+	var schedules []model.ScheduledTransfer
+	if err := s.db.Where("origin_account_id = ?", accountID).Order("id DESC").Find(&schedules).Error; err != nil {
+		logger.From(ctx).Errorw("Failed to list schedules", "account_id", accountID, "error", err)
+		return nil, &ServiceError{Message: "Failed to list schedules", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	result := make([]model.ScheduledTransferResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		result = append(result, schedule.ToResponse())
+	}
+	return result, nil
+}
+
+// GetSchedule returns the schedule with its most recent runs.
+func (s *scheduledTransferService) GetSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	schedule, serr := findSchedule(s.db, scheduleID, ctx)
+	if serr != nil {
+		return model.ScheduledTransferResponse{}, serr
+	}
+	if serr := authorizeSchedule(&schedule, constant.ScopeTransfersReadAny, ctx); serr != nil {
+		return model.ScheduledTransferResponse{}, serr
+	}
+
+	var executions []model.ScheduledTransferExecution
+	err := s.db.Where("scheduled_transfer_id = ?", schedule.ID).
+		Order("scheduled_for DESC").Limit(maxScheduleExecutions).Find(&executions).Error
+	if err != nil {
+		logger.From(ctx).Errorw("Failed to load schedule executions", "schedule_id", schedule.ID, "error", err)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Failed to load schedule", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	response := schedule.ToResponse()
+	response.Executions = executions
+	return response, nil
+}
+
+func (s *scheduledTransferService) PauseSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	return s.changeStatus(scheduleID, []string{constant.ScheduleStatusActive}, constant.ScheduleStatusPaused, ctx)
+}
+
+// ResumeSchedule reactivates a paused schedule. Runs that fell due while it
+// was paused are skipped.
+func (s *scheduledTransferService) ResumeSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	return s.changeStatus(scheduleID, []string{constant.ScheduleStatusPaused}, constant.ScheduleStatusActive, ctx)
+}
+
+func (s *scheduledTransferService) CancelSchedule(scheduleID string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	return s.changeStatus(scheduleID, []string{constant.ScheduleStatusActive, constant.ScheduleStatusPaused}, constant.ScheduleStatusCancelled, ctx)
+}
+
+func (s *scheduledTransferService) changeStatus(scheduleID string, from []string, to string, ctx *gin.Context) (model.ScheduledTransferResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	tx := s.db.Begin()
+
+	schedule, serr := findSchedule(tx.Clauses(clause.Locking{Strength: "UPDATE"}), scheduleID, ctx)
+	if serr != nil {
+		tx.Rollback()
+		return model.ScheduledTransferResponse{}, serr
+	}
+	if serr := authorizeSchedule(&schedule, constant.ScopeAdmin, ctx); serr != nil {
+		tx.Rollback()
+		return model.ScheduledTransferResponse{}, serr
+	}
+
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || schedule.Status == status
+	}
+	if !allowed {
+		tx.Rollback()
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Schedule is " + schedule.Status, Code: http.StatusConflict}
+	}
+
+	schedule.Status = to
+	switch to {
+	case constant.ScheduleStatusCancelled:
+		schedule.NextRunAt = nil
+	case constant.ScheduleStatusActive:
+		if now := time.Now().UTC(); schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			advanceSchedule(&schedule, now)
+		}
+	}
+
+	if err := tx.Save(&schedule).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Failed to update schedule status", "schedule_id", schedule.ID, "error", err)
+		return model.ScheduledTransferResponse{}, &ServiceError{Message: "Unable to update schedule", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	tx.Commit()
+	log.Infow("Schedule status changed", "schedule_id", schedule.ID, "to", schedule.Status)
+	return schedule.ToResponse(), nil
+}
+
+// CronRunDueSchedules creates the transfers of every active schedule whose
+// next run is due. A failed run is recorded and the schedule moves on.
+func (s *scheduledTransferService) CronRunDueSchedules() *ServiceError {
+	now := time.Now().UTC()
+
+	var due []model.ScheduledTransfer
+	err := s.db.Where("status = ? AND next_run_at <= ?", constant.ScheduleStatusActive, now).
+		Order("next_run_at").Find(&due).Error
+	if err != nil {
+		return &ServiceError{Message: "Failed to load due schedules", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	succeeded, failed := 0, 0
+	for _, candidate := range due {
+		execution, ok, err := s.claimRun(candidate.ID, now)
+		if err != nil {
+			return &ServiceError{Message: "Failed to run schedule", Code: http.StatusInternalServerError, Error: err}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := s.execute(&candidate, &execution); err != nil {
+			return &ServiceError{Message: "Failed to record schedule run", Code: http.StatusInternalServerError, Error: err}
+		}
+		if execution.Status == constant.ScheduleExecutionSucceeded {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+
+	if succeeded+failed > 0 {
+		log.Println("[Cron] Ran scheduled transfers", "succeeded", succeeded, "failed", failed)
+	}
+
+	return nil
+}
+
+// claimRun records a pending run of the schedule and moves it on to its next
+// occurrence in one transaction, so a run is never attempted twice even if
+// the transfer can't be created. ok is false if it's no longer due.
+func (s *scheduledTransferService) claimRun(scheduleID uint, now time.Time) (model.ScheduledTransferExecution, bool, error) {
+	tx := s.db.Begin()
+
+	var schedule model.ScheduledTransfer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, scheduleID).Error; err != nil {
+		tx.Rollback()
+		return model.ScheduledTransferExecution{}, false, err
+	}
+	// Paused, cancelled or already run since it was listed.
+	if schedule.Status != constant.ScheduleStatusActive || schedule.NextRunAt == nil || schedule.NextRunAt.After(now) {
+		tx.Rollback()
+		return model.ScheduledTransferExecution{}, false, nil
+	}
+
+	execution := model.ScheduledTransferExecution{
+		ScheduledTransferID: schedule.ID,
+		ScheduledFor:        *schedule.NextRunAt,
+		Status:              constant.ScheduleExecutionPending,
+	}
+	if err := tx.Create(&execution).Error; err != nil {
+		tx.Rollback()
+		return model.ScheduledTransferExecution{}, false, err
+	}
+
+	schedule.RunCount++
+	advanceSchedule(&schedule, now)
+	if err := tx.Save(&schedule).Error; err != nil {
+		tx.Rollback()
+		return model.ScheduledTransferExecution{}, false, err
+	}
+
+	return execution, true, tx.Commit().Error
+}
+
+// execute creates the run's transfer on behalf of the origin account and
+// records the outcome.
+func (s *scheduledTransferService) execute(schedule *model.ScheduledTransfer, execution *model.ScheduledTransferExecution) error {
+	ctx := actingAs(schedule.OriginAccountID, fmt.Sprintf("schedule:%d", schedule.ID))
+
+	req := model.TransferRequest{
+		OriginAccountID:      schedule.OriginAccountID,
+		DestinationAccountID: schedule.DestinationAccountID,
+		Amount:               json.Number(schedule.Amount.Format(money.CurrencyFor(schedule.Currency))),
+		Currency:             schedule.Currency,
+	}
+	transfer, serr := s.transfers.CreateTransfer(&req, ctx)
+	if serr != nil {
+		execution.Status = constant.ScheduleExecutionFailed
+		execution.Error = serr.Message
+	} else {
+		execution.Status = constant.ScheduleExecutionSucceeded
+		execution.TransferID = &transfer.ID
+	}
+	return s.db.Save(execution).Error
+}
+
+// actingAs builds a context for background work done on behalf of an
+// account, outside of any request.
+func actingAs(accountID uint, subject string) *gin.Context {
+	ctx := &gin.Context{}
+	auth.SetPrincipal(ctx, auth.Principal{
+		AccountID: fmt.Sprint(accountID),
+		Subject:   subject,
+		Role:      constant.RoleCustomer,
+		Scopes:    []string{constant.ScopeTransfersWrite},
+	})
+	return ctx
+}
+
+func findSchedule(db *gorm.DB, scheduleID string, ctx *gin.Context) (model.ScheduledTransfer, *ServiceError) {
+	id, err := strconv.ParseUint(scheduleID, 10, 64)
+	if err != nil {
+		return model.ScheduledTransfer{}, &ServiceError{Message: "Schedule not found", Code: http.StatusNotFound}
+	}
+
+	var schedule model.ScheduledTransfer
+	if err := db.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ScheduledTransfer{}, &ServiceError{Message: "Schedule not found", Code: http.StatusNotFound}
+		}
+		logger.From(ctx).Errorw("Failed to load schedule", "schedule_id", scheduleID, "error", err)
+		return model.ScheduledTransfer{}, &ServiceError{Message: "Failed to load schedule", Code: http.StatusInternalServerError, Error: err}
+	}
+	return schedule, nil
+}
+
+// authorizeSchedule lets the origin account's owner through, and staff who
+// hold staffScope.
+func authorizeSchedule(schedule *model.ScheduledTransfer, staffScope string, ctx *gin.Context) *ServiceError {
+	principal, _ := auth.PrincipalFrom(ctx)
+	if principal.HasScope(staffScope) || auth.OwnsAccount(ctx, fmt.Sprint(schedule.OriginAccountID)) {
+		return nil
+	}
+
+	logger.From(ctx).Warnw("Forbidden schedule request", "schedule_id", schedule.ID, "caller", principal.Subject)
+	return &ServiceError{Message: "Forbidden", Code: http.StatusForbidden}
+}
+
+// advanceSchedule moves NextRunAt to the first occurrence after now, or ends
+// the schedule when there is none left. Occurrences are counted from RunAt,
+// so monthly runs keep their day of the month after a shorter month.
+func advanceSchedule(schedule *model.ScheduledTransfer, now time.Time) {
+	if schedule.Frequency == "" || (schedule.MaxRuns > 0 && schedule.RunCount >= schedule.MaxRuns) {
+		completeSchedule(schedule)
+		return
+	}
+
+	after := now
+	if schedule.NextRunAt != nil && schedule.NextRunAt.After(after) {
+		after = *schedule.NextRunAt
+	}
+
+	var next time.Time
+	for n := 1; ; n++ {
+		next = occurrence(schedule.RunAt, schedule.Frequency, n)
+		if next.After(after) {
+			break
+		}
+	}
+
+	if schedule.EndDate != nil && next.After(*schedule.EndDate) {
+		completeSchedule(schedule)
+		return
+	}
+	schedule.NextRunAt = &next
+}
+
+func completeSchedule(schedule *model.ScheduledTransfer) {
+	schedule.Status = constant.ScheduleStatusCompleted
+	schedule.NextRunAt = nil
+}
+
+// occurrence is the nth run after start. Monthly runs fall on start's day of
+// the month, or the month's last day when it is shorter.
+func occurrence(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case constant.ScheduleFrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case constant.ScheduleFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	}
+
+	year, month, day := start.Date()
+	firstOfMonth := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	if lastDay := firstOfMonth.AddDate(0, 1, -1).Day(); day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,186 @@
+package service_test
+
+import (
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupScheduleTestDB() *gorm.DB {
+	db := setupTransferTestDB()
+	if err := db.AutoMigrate(&model.ScheduledTransfer{}, &model.ScheduledTransferExecution{}); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func newScheduleService(db *gorm.DB) service.ScheduledTransferService {
+	return service.NewScheduledTransferService(db, service.NewTransferService(db, 5*time.Minute))
+}
+
+// makeDue moves a schedule's next run into the past.
+func makeDue(db *gorm.DB, scheduleID uint, at time.Time) {
+	db.Model(&model.ScheduledTransfer{}).Where("id = ?", scheduleID).
+		Updates(map[string]interface{}{"run_at": at.UTC(), "next_run_at": at.UTC()})
+}
+
+func TestCreateSchedule_Validation(t *testing.T) {
+	db := setupScheduleTestDB()
+	scheduleService := newScheduleService(db)
+	logger.Init("test")
+
+	future := time.Now().Add(time.Hour)
+
+	_, err := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", RunAt: time.Now().Add(-time.Minute)}, authedContext(1))
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", RunAt: future, Frequency: "YEARLY"}, authedContext(1))
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", RunAt: future, Count: 3}, authedContext(1))
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 3, Amount: "10.00", RunAt: future}, authedContext(1))
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+
+	_, err = scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", RunAt: future}, authedContext(2))
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	schedule, err := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", RunAt: future, Frequency: "monthly", Count: 12}, authedContext(1))
+	assert.Nil(t, err)
+	assert.Equal(t, constant.ScheduleFrequencyMonthly, schedule.Frequency)
+	assert.Equal(t, constant.ScheduleStatusActive, schedule.Status)
+	assert.Equal(t, "10.00", schedule.Amount)
+}
+
+func TestCronRunDueSchedules_OneOff(t *testing.T) {
+	db := setupScheduleTestDB()
+	scheduleService := newScheduleService(db)
+	logger.Init("test")
+
+	created, serr := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "25.00", RunAt: time.Now().Add(time.Hour)}, authedContext(1))
+	assert.Nil(t, serr)
+
+	// Not due yet.
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+	var count int64
+	db.Model(&model.Transfer{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	makeDue(db, created.ID, time.Now().Add(-time.Minute))
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+
+	schedule, serr := scheduleService.GetSchedule(fmt.Sprint(created.ID), authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.ScheduleStatusCompleted, schedule.Status)
+	assert.Nil(t, schedule.NextRunAt)
+	assert.Equal(t, 1, schedule.RunCount)
+	assert.Len(t, schedule.Executions, 1)
+	assert.Equal(t, constant.ScheduleExecutionSucceeded, schedule.Executions[0].Status)
+
+	var transfer model.Transfer
+	assert.NoError(t, db.First(&transfer, *schedule.Executions[0].TransferID).Error)
+	assert.Equal(t, constant.TransferStatusPending, transfer.Status)
+	assert.Equal(t, "25.00", transfer.ToResponse().Amount)
+}
+
+func TestCronRunDueSchedules_RecordsFailureAndMovesOn(t *testing.T) {
+	db := setupScheduleTestDB()
+	scheduleService := newScheduleService(db)
+	logger.Init("test")
+
+	// More than account 1 holds.
+	created, serr := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "500.00", RunAt: time.Now().Add(time.Hour), Frequency: "DAILY"}, authedContext(1))
+	assert.Nil(t, serr)
+
+	// Three days of missed runs are skipped, not made up.
+	makeDue(db, created.ID, time.Now().Add(-72*time.Hour+time.Minute))
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+
+	schedule, serr := scheduleService.GetSchedule(fmt.Sprint(created.ID), authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.ScheduleStatusActive, schedule.Status)
+	assert.True(t, schedule.NextRunAt.After(time.Now()))
+	assert.True(t, schedule.NextRunAt.Before(time.Now().Add(24*time.Hour)))
+	assert.Len(t, schedule.Executions, 1)
+	assert.Equal(t, constant.ScheduleExecutionFailed, schedule.Executions[0].Status)
+	assert.Equal(t, "Insufficient funds", schedule.Executions[0].Error)
+	assert.Nil(t, schedule.Executions[0].TransferID)
+}
+
+func TestCronRunDueSchedules_MonthlyKeepsDayAndStopsAtCount(t *testing.T) {
+	db := setupScheduleTestDB()
+	scheduleService := newScheduleService(db)
+	logger.Init("test")
+
+	created, serr := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "1.00", RunAt: time.Now().Add(time.Hour), Frequency: "MONTHLY", Count: 2}, authedContext(1))
+	assert.Nil(t, serr)
+
+	start := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+	makeDue(db, created.ID, start)
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+
+	schedule, _ := scheduleService.GetSchedule(fmt.Sprint(created.ID), authedContext(1))
+	next := *schedule.NextRunAt
+	lastDay := time.Date(next.Year(), next.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	assert.Equal(t, min(31, lastDay), next.Day())
+	assert.Equal(t, 9, next.Hour())
+
+	makeDue(db, created.ID, time.Now().Add(-time.Minute))
+	db.Model(&model.ScheduledTransfer{}).Where("id = ?", created.ID).Update("run_at", start)
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+
+	schedule, _ = scheduleService.GetSchedule(fmt.Sprint(created.ID), authedContext(1))
+	assert.Equal(t, constant.ScheduleStatusCompleted, schedule.Status)
+	assert.Equal(t, 2, schedule.RunCount)
+	assert.Len(t, schedule.Executions, 2)
+}
+
+func TestScheduleStatusChanges(t *testing.T) {
+	db := setupScheduleTestDB()
+	scheduleService := newScheduleService(db)
+	logger.Init("test")
+
+	created, serr := scheduleService.CreateSchedule(&model.ScheduledTransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "1.00", RunAt: time.Now().Add(time.Hour), Frequency: "WEEKLY"}, authedContext(1))
+	assert.Nil(t, serr)
+	id := fmt.Sprint(created.ID)
+
+	_, serr = scheduleService.PauseSchedule(id, authedContext(2))
+	assert.Equal(t, http.StatusForbidden, serr.Code)
+
+	paused, serr := scheduleService.PauseSchedule(id, authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.ScheduleStatusPaused, paused.Status)
+
+	// Paused schedules don't run.
+	makeDue(db, created.ID, time.Now().Add(-time.Minute))
+	assert.Nil(t, scheduleService.CronRunDueSchedules())
+	var executions int64
+	db.Model(&model.ScheduledTransferExecution{}).Count(&executions)
+	assert.Equal(t, int64(0), executions)
+
+	resumed, serr := scheduleService.ResumeSchedule(id, authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.ScheduleStatusActive, resumed.Status)
+	assert.True(t, resumed.NextRunAt.After(time.Now()))
+
+	cancelled, serr := scheduleService.CancelSchedule(id, authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.ScheduleStatusCancelled, cancelled.Status)
+	assert.Nil(t, cancelled.NextRunAt)
+
+	_, serr = scheduleService.ResumeSchedule(id, authedContext(1))
+	assert.Equal(t, http.StatusConflict, serr.Code)
+
+	_, serr = scheduleService.GetSchedule("abc", authedContext(1))
+	assert.Equal(t, http.StatusNotFound, serr.Code)
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type TokenService interface {
+	Login(req *model.LoginRequest, ctx *gin.Context) (model.TokenResponse, *ServiceError)
+	Refresh(req *model.RefreshRequest, ctx *gin.Context) (model.TokenResponse, *ServiceError)
+	Logout(req *model.LogoutRequest, ctx *gin.Context) *ServiceError
+	IssueTestToken(accountID string, ctx *gin.Context) (model.TokenResponse, *ServiceError)
+	SetCredentials(req *model.CredentialRequest, ctx *gin.Context) (model.CredentialResponse, *ServiceError)
+	BootstrapAdmin(username, password string) (bool, error)
+	IsRevoked(jti string) (bool, error)
+	JWKS() auth.JWKS
+	CronPurgeRevokedTokens() *ServiceError
+}
+
+type tokenService struct {
+	db         *gorm.DB
+	issuer     *auth.Issuer
+	refreshTTL time.Duration
+}
+
+func NewTokenService(db *gorm.DB, issuer *auth.Issuer, refreshTTL time.Duration) TokenService {
+	return &tokenService{db: db, issuer: issuer, refreshTTL: refreshTTL}
+}
+
+func (s *tokenService) Login(req *model.LoginRequest, ctx *gin.Context) (model.TokenResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	var credential model.AccountCredential
+	err := s.db.Where("username = ?", req.Username).First(&credential).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Errorw("Login failed: Unable to load credentials", "error", err)
+		return model.TokenResponse{}, &ServiceError{Message: "Login failed", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(req.Password)) != nil {
+		log.Warnw("Login failed: Invalid credentials", "username", req.Username)
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid username or password", Code: http.StatusUnauthorized}
+	}
+
+	return s.issuePair(credential, ctx)
+}
+
+// Refresh trades a refresh token for a new access/refresh pair. Refresh
+// tokens are single-use; presenting one that was already used revokes every
+// refresh token of the login, since it means the token has leaked. The role
+// is re-read, so a changed role takes effect on the next refresh.
+func (s *tokenService) Refresh(req *model.RefreshRequest, ctx *gin.Context) (model.TokenResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	var stored model.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		log.Warnw("Refresh failed: Unknown refresh token")
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid refresh token", Code: http.StatusUnauthorized}
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil {
+		log.Warnw("Refresh failed: Reused refresh token, revoking all sessions", "credential_id", stored.CredentialID)
+		s.db.Model(&model.RefreshToken{}).Where("credential_id = ? AND revoked_at IS NULL", stored.CredentialID).Update("revoked_at", now)
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid refresh token", Code: http.StatusUnauthorized}
+	}
+
+	if now.After(stored.ExpiresAt) {
+		log.Warnw("Refresh failed: Expired refresh token", "credential_id", stored.CredentialID)
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid refresh token", Code: http.StatusUnauthorized}
+	}
+
+	result := s.db.Model(&model.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", stored.ID).Update("revoked_at", now)
+	if result.Error != nil {
+		log.Errorw("Refresh failed: Unable to rotate refresh token", "error", result.Error)
+		return model.TokenResponse{}, &ServiceError{Message: "Refresh failed", Code: http.StatusInternalServerError, Error: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		log.Warnw("Refresh failed: Refresh token used concurrently", "credential_id", stored.CredentialID)
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid refresh token", Code: http.StatusUnauthorized}
+	}
+
+	var credential model.AccountCredential
+	if err := s.db.First(&credential, stored.CredentialID).Error; err != nil {
+		log.Warnw("Refresh failed: Login no longer exists", "credential_id", stored.CredentialID)
+		return model.TokenResponse{}, &ServiceError{Message: "Invalid refresh token", Code: http.StatusUnauthorized}
+	}
+
+	return s.issuePair(credential, ctx)
+}
+
+// Logout puts the caller's access token on the denylist until it expires and
+// revokes the given refresh token, if any.
+func (s *tokenService) Logout(req *model.LogoutRequest, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return &ServiceError{Message: "Unauthorized", Code: http.StatusUnauthorized}
+	}
+
+	jti, _ := principal.Claims["jti"].(string)
+	expiresAt, err := principal.Claims.GetExpirationTime()
+	if jti == "" || err != nil || expiresAt == nil {
+		log.Errorw("Logout failed: Token has no jti or exp")
+		return &ServiceError{Message: "Unauthorized", Code: http.StatusUnauthorized}
+	}
+
+	if err := s.db.Create(&model.RevokedToken{JTI: jti, ExpiresAt: expiresAt.Time}).Error; err != nil {
+		log.Errorw("Logout failed: Unable to revoke access token", "error", err)
+		return &ServiceError{Message: "Logout failed", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if req.RefreshToken != "" {
+		err := s.db.Model(&model.RefreshToken{}).
+			Where("token_hash = ? AND revoked_at IS NULL", hashToken(req.RefreshToken)).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			log.Errorw("Logout failed: Unable to revoke refresh token", "error", err)
+			return &ServiceError{Message: "Logout failed", Code: http.StatusInternalServerError, Error: err}
+		}
+	}
+
+	log.Infow("Logged out", "subject", principal.Subject, "jti", jti)
+	return nil
+}
+
+// IssueTestToken mints a customer access token for any account without
+// credentials. Only routed when APP_ENV is development.
+func (s *tokenService) IssueTestToken(accountID string, ctx *gin.Context) (model.TokenResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	issued, err := s.issuer.Issue(auth.Principal{
+		AccountID: accountID,
+		Role:      constant.RoleCustomer,
+		Scopes:    constant.RoleScopes[constant.RoleCustomer],
+	})
+	if err != nil {
+		log.Errorw("Failed to create token", "error", err)
+		return model.TokenResponse{}, &ServiceError{Message: "Failed to create token", Code: http.StatusInternalServerError, Error: err}
+	}
+	return model.TokenResponse{AccessToken: issued.Token, TokenType: "Bearer", ExpiresAt: issued.ExpiresAt}, nil
+}
+
+// SetCredentials creates a login. Customer logins must name an existing
+// account; staff logins must not name one.
+func (s *tokenService) SetCredentials(req *model.CredentialRequest, ctx *gin.Context) (model.CredentialResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	role := req.Role
+	if role == "" {
+		role = constant.RoleCustomer
+	}
+	if _, ok := constant.RoleScopes[role]; !ok {
+		log.Warnw("Failed to store credentials: Unknown role", "role", role)
+		return model.CredentialResponse{}, &ServiceError{Message: "Unknown role", Code: http.StatusBadRequest}
+	}
+	if (role == constant.RoleCustomer) != (req.AccountID != nil) {
+		log.Warnw("Failed to store credentials: Account does not match role", "role", role)
+		return model.CredentialResponse{}, &ServiceError{Message: "Customer logins need an account_id; staff logins must not have one", Code: http.StatusBadRequest}
+	}
+	if req.AccountID != nil {
+		if err := s.db.First(&model.Account{}, *req.AccountID).Error; err != nil {
+			log.Warnw("Failed to store credentials: Account not found", "account_id", *req.AccountID)
+			return model.CredentialResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound, Error: err}
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Errorw("Failed to hash password", "error", err)
+		return model.CredentialResponse{}, &ServiceError{Message: "Invalid password", Code: http.StatusBadRequest, Error: err}
+	}
+
+	credential := model.AccountCredential{AccountID: req.AccountID, Role: role, Username: req.Username, PasswordHash: string(hash)}
+	if err := s.db.Create(&credential).Error; err != nil {
+		log.Errorw("Failed to store credentials", "username", req.Username, "error", err)
+		return model.CredentialResponse{}, &ServiceError{Message: "Unable to store credentials", Code: http.StatusConflict, Error: err}
+	}
+
+	log.Infow("Stored credentials", "credential_id", credential.ID, "role", role)
+	return model.CredentialResponse{
+		ID:        credential.ID,
+		AccountID: credential.AccountID,
+		Username:  credential.Username,
+		Role:      credential.Role,
+		CreatedAt: credential.CreatedAt,
+	}, nil
+}
+
+// BootstrapAdmin creates the first admin login, since only an admin can
+// create logins. It does nothing once any admin login exists, so it is safe
+// to run on every start of every instance. It reports whether it created one.
+func (s *tokenService) BootstrapAdmin(username, password string) (bool, error) {
+	if password == "" {
+		return false, errors.New("a password is required")
+	}
+
+	exists, err := s.adminExists()
+	if err != nil || exists {
+		return false, err
+	}
+
+	req := &model.CredentialRequest{Username: username, Password: password, Role: constant.RoleAdmin}
+	if _, serr := s.SetCredentials(req, &gin.Context{}); serr != nil {
+		// Another instance starting at the same time may have created it.
+		if exists, err := s.adminExists(); err == nil && exists {
+			return false, nil
+		}
+		if serr.Error != nil {
+			return false, fmt.Errorf("%s: %w", serr.Message, serr.Error)
+		}
+		return false, errors.New(serr.Message)
+	}
+	return true, nil
+}
+
+func (s *tokenService) adminExists() (bool, error) {
+	var count int64
+	err := s.db.Model(&model.AccountCredential{}).Where("role = ?", constant.RoleAdmin).Count(&count).Error
+	return count > 0, err
+}
+
+func (s *tokenService) JWKS() auth.JWKS {
+	return s.issuer.JWKS()
+}
+
+func (s *tokenService) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := s.db.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+func (s *tokenService) CronPurgeRevokedTokens() *ServiceError {
+	now := time.Now()
+	revoked := s.db.Where("expires_at < ?", now).Delete(&model.RevokedToken{})
+	if revoked.Error != nil {
+		return &ServiceError{Message: "Failed to purge revoked tokens", Code: http.StatusInternalServerError, Error: revoked.Error}
+	}
+
+	refresh := s.db.Where("expires_at < ?", now).Delete(&model.RefreshToken{})
+	if refresh.Error != nil {
+		return &ServiceError{Message: "Failed to purge refresh tokens", Code: http.StatusInternalServerError, Error: refresh.Error}
+	}
+
+	if revoked.RowsAffected+refresh.RowsAffected > 0 {
+		log.Println("[Cron] Purged expired tokens", "revoked", revoked.RowsAffected, "refresh", refresh.RowsAffected)
+	}
+
+	return nil
+}
+
+func (s *tokenService) issuePair(credential model.AccountCredential, ctx *gin.Context) (model.TokenResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	issued, err := s.issuer.Issue(principalFor(credential))
+	if err != nil {
+		log.Errorw("Failed to create token", "error", err)
+		return model.TokenResponse{}, &ServiceError{Message: "Failed to create token", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Errorw("Failed to create refresh token", "error", err)
+		return model.TokenResponse{}, &ServiceError{Message: "Failed to create token", Code: http.StatusInternalServerError, Error: err}
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	stored := model.RefreshToken{
+		AccountID:    credential.AccountID,
+		CredentialID: credential.ID,
+		TokenHash:    hashToken(refreshToken),
+		ExpiresAt:    time.Now().Add(s.refreshTTL),
+	}
+	if err := s.db.Create(&stored).Error; err != nil {
+		log.Errorw("Failed to store refresh token", "error", err)
+		return model.TokenResponse{}, &ServiceError{Message: "Failed to create token", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Issued tokens", "credential_id", credential.ID, "role", credential.Role, "jti", issued.JTI)
+	return model.TokenResponse{
+		AccessToken:  issued.Token,
+		TokenType:    "Bearer",
+		ExpiresAt:    issued.ExpiresAt,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// principalFor maps a login to the token's principal. Customers are
+// identified by their account; staff by their username.
+func principalFor(credential model.AccountCredential) auth.Principal {
+	principal := auth.Principal{
+		Subject: "staff:" + credential.Username,
+		Role:    credential.Role,
+		Scopes:  constant.RoleScopes[credential.Role],
+	}
+	if credential.AccountID != nil {
+		principal.AccountID = fmt.Sprint(*credential.AccountID)
+		principal.Subject = principal.AccountID
+	}
+	return principal
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,205 @@
+package service_test
+
+import (
+	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTokenTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	err = db.AutoMigrate(&model.Account{}, &model.AccountCredential{}, &model.RefreshToken{}, &model.RevokedToken{})
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db.Create(&model.Account{Name: "Test Account"})
+
+	return db
+}
+
+func setupTokenService(db *gorm.DB) (service.TokenService, *auth.Issuer) {
+	issuer, err := auth.NewIssuer([]auth.Key{auth.NewHMACKey("test", "test-secret")}, "test", nil, 15*time.Minute)
+	if err != nil {
+		log.Fatalf("failed to create issuer: %v", err)
+	}
+	tokenService := service.NewTokenService(db, issuer, time.Hour)
+	accountID := uint(1)
+	req := &model.CredentialRequest{AccountID: &accountID, Username: "alice", Password: "correct horse"}
+	if _, err := tokenService.SetCredentials(req, &gin.Context{}); err != nil {
+		log.Fatalf("failed to set credentials: %v", err.Message)
+	}
+	return tokenService, issuer
+}
+
+func TestLogin_IssuesExpiringTokens(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, issuer := setupTokenService(db)
+
+	ctx := &gin.Context{}
+
+	tokens, err := tokenService.Login(&model.LoginRequest{Username: "alice", Password: "correct horse"}, ctx)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	claims, parseErr := issuer.Parse(tokens.AccessToken)
+	assert.Nil(t, parseErr)
+	assert.Equal(t, "1", claims["id"])
+	assert.Equal(t, constant.RoleCustomer, claims["role"])
+	assert.Equal(t, "accounts:read transfers:read transfers:write", claims["scope"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.NotNil(t, claims["exp"])
+	assert.NotNil(t, claims["iat"])
+}
+
+func TestLogin_InvalidPassword(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, _ := setupTokenService(db)
+
+	ctx := &gin.Context{}
+
+	_, err := tokenService.Login(&model.LoginRequest{Username: "alice", Password: "wrong"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, err.Code)
+
+	_, err = tokenService.Login(&model.LoginRequest{Username: "bob", Password: "wrong"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, err.Code)
+}
+
+func TestRefresh_RotatesAndDetectsReuse(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, _ := setupTokenService(db)
+
+	ctx := &gin.Context{}
+
+	first, err := tokenService.Login(&model.LoginRequest{Username: "alice", Password: "correct horse"}, ctx)
+	assert.Nil(t, err)
+
+	second, err := tokenService.Refresh(&model.RefreshRequest{RefreshToken: first.RefreshToken}, ctx)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// Reusing the rotated token revokes the whole family.
+	_, err = tokenService.Refresh(&model.RefreshRequest{RefreshToken: first.RefreshToken}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, err.Code)
+
+	_, err = tokenService.Refresh(&model.RefreshRequest{RefreshToken: second.RefreshToken}, ctx)
+	assert.NotNil(t, err)
+}
+
+func TestLogout_RevokesAccessToken(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, issuer := setupTokenService(db)
+
+	tokens, err := tokenService.Login(&model.LoginRequest{Username: "alice", Password: "correct horse"}, &gin.Context{})
+	assert.Nil(t, err)
+
+	claims, _ := issuer.Parse(tokens.AccessToken)
+	ctx := &gin.Context{}
+	auth.SetPrincipal(ctx, auth.Principal{AccountID: "1", Claims: claims})
+
+	assert.Nil(t, tokenService.Logout(&model.LogoutRequest{RefreshToken: tokens.RefreshToken}, ctx))
+
+	revoked, revokedErr := tokenService.IsRevoked(claims["jti"].(string))
+	assert.Nil(t, revokedErr)
+	assert.True(t, revoked)
+
+	_, err = tokenService.Refresh(&model.RefreshRequest{RefreshToken: tokens.RefreshToken}, &gin.Context{})
+	assert.NotNil(t, err)
+}
+
+func TestLogin_StaffTokensCarryRoleScopes(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, issuer := setupTokenService(db)
+
+	ctx := &gin.Context{}
+
+	_, err := tokenService.SetCredentials(&model.CredentialRequest{Username: "sam", Password: "support pass", Role: constant.RoleSupport}, ctx)
+	assert.Nil(t, err)
+
+	tokens, err := tokenService.Login(&model.LoginRequest{Username: "sam", Password: "support pass"}, ctx)
+	assert.Nil(t, err)
+
+	claims, parseErr := issuer.Parse(tokens.AccessToken)
+	assert.Nil(t, parseErr)
+	assert.NotContains(t, claims, "id")
+	assert.Equal(t, "staff:sam", claims["sub"])
+	assert.Equal(t, constant.RoleSupport, claims["role"])
+	assert.Contains(t, claims["scope"], constant.ScopeAccountsReadAny)
+	assert.NotContains(t, claims["scope"], constant.ScopeTransfersWrite)
+
+	refreshed, err := tokenService.Refresh(&model.RefreshRequest{RefreshToken: tokens.RefreshToken}, ctx)
+	assert.Nil(t, err)
+	claims, _ = issuer.Parse(refreshed.AccessToken)
+	assert.Equal(t, constant.RoleSupport, claims["role"])
+}
+
+func TestSetCredentials_ValidatesRoleAndAccount(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, _ := setupTokenService(db)
+
+	ctx := &gin.Context{}
+	accountID := uint(1)
+
+	_, err := tokenService.SetCredentials(&model.CredentialRequest{Username: "x", Password: "p", Role: "superuser"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = tokenService.SetCredentials(&model.CredentialRequest{Username: "x", Password: "p"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = tokenService.SetCredentials(&model.CredentialRequest{AccountID: &accountID, Username: "x", Password: "p", Role: constant.RoleAdmin}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+}
+
+func TestBootstrapAdmin_CreatesFirstAdminOnce(t *testing.T) {
+	db := setupTokenTestDB()
+	logger.Init("test")
+	tokenService, issuer := setupTokenService(db)
+
+	_, err := tokenService.BootstrapAdmin("root", "")
+	assert.Error(t, err)
+
+	created, err := tokenService.BootstrapAdmin("root", "s3cret-root")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	tokens, serr := tokenService.Login(&model.LoginRequest{Username: "root", Password: "s3cret-root"}, &gin.Context{})
+	assert.Nil(t, serr)
+	claims, parseErr := issuer.Parse(tokens.AccessToken)
+	assert.Nil(t, parseErr)
+	assert.Equal(t, constant.RoleAdmin, claims["role"])
+
+	// Later starts leave the existing admin alone, even with other values.
+	created, err = tokenService.BootstrapAdmin("root2", "other")
+	assert.NoError(t, err)
+	assert.False(t, created)
+	var count int64
+	db.Model(&model.AccountCredential{}).Where("role = ?", constant.RoleAdmin).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
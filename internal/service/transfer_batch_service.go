@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxBatchItems bounds the size of a single batch.
+	maxBatchItems = 10000
+	// maxAtomicBatchItems bounds ALL_OR_NOTHING batches, which run in one
+	// transaction holding the origin and destination account rows, so that
+	// transaction stays short and well inside batchClaimTTL.
+	maxAtomicBatchItems = 500
+	// batchWorkers bounds how many submitted batches are processed at once.
+	// Batches beyond that wait for CronResumeBatches.
+	batchWorkers = 4
+	// batchClaimTTL is how long a worker may go without progress before its
+	// batch is considered abandoned and picked up again.
+	batchClaimTTL = 10 * time.Minute
+	// batchHeartbeatEvery is how many best-effort items a worker processes
+	// between renewals of its claim.
+	batchHeartbeatEvery = 100
+)
+
+type TransferBatchService interface {
+	SubmitBatch(mode string, items []model.TransferRequest, ctx *gin.Context) (model.TransferBatchResponse, []model.TransferBatchItemError, *ServiceError)
+	GetBatch(batchID string, ctx *gin.Context) (model.TransferBatchResponse, *ServiceError)
+	CronResumeBatches(jobCtx context.Context) *ServiceError
+}
+
+type transferBatchService struct {
+	db      *gorm.DB
+	expiry  time.Duration
+	workers chan struct{}
+}
+
+// NewTransferBatchService creates the service; expiry is the default pending
+// timeout of the transfers it creates, as for NewTransferService.
+func NewTransferBatchService(db *gorm.DB, expiry time.Duration) TransferBatchService {
+	return &transferBatchService{db: db, expiry: expiry, workers: make(chan struct{}, batchWorkers)}
+}
+
+// SubmitBatch validates every item and stores the batch, then processes it
+// in the background. In ALL_OR_NOTHING mode a single invalid item rejects the
+// whole batch with the item errors; in BEST_EFFORT mode invalid items are
+// stored as failed and the rest go ahead.
+func (s *transferBatchService) SubmitBatch(mode string, items []model.TransferRequest, ctx *gin.Context) (model.TransferBatchResponse, []model.TransferBatchItemError, *ServiceError) {
+	log := logger.From(ctx)
+
+	mode = strings.ToUpper(mode)
+	if mode == "" {
+		mode = constant.BatchModeAllOrNothing
+	}
+	if mode != constant.BatchModeAllOrNothing && mode != constant.BatchModeBestEffort {
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: "mode must be ALL_OR_NOTHING or BEST_EFFORT", Code: http.StatusBadRequest}
+	}
+
+	if len(items) == 0 || len(items) > maxBatchItems {
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: fmt.Sprintf("A batch must have between 1 and %d items", maxBatchItems), Code: http.StatusBadRequest}
+	}
+	if mode == constant.BatchModeAllOrNothing && len(items) > maxAtomicBatchItems {
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: fmt.Sprintf("An ALL_OR_NOTHING batch can have at most %d items", maxAtomicBatchItems), Code: http.StatusBadRequest}
+	}
+
+	accountID, err := strconv.ParseUint(auth.AccountID(ctx), 10, 64)
+	if err != nil {
+		log.Warnw("Batch rejected: Caller has no account", "caller", auth.AccountID(ctx))
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: "Only accounts can submit batches", Code: http.StatusForbidden}
+	}
+
+	batch := model.TransferBatch{
+		AccountID:  uint(accountID),
+		Mode:       mode,
+		Status:     constant.BatchStatusPending,
+		TotalItems: len(items),
+	}
+	batchItems := make([]model.TransferBatchItem, len(items))
+	var itemErrors []model.TransferBatchItemError
+	for i := range items {
+		req := &items[i]
+		batchItems[i] = model.TransferBatchItem{
+			Position:             i,
+			OriginAccountID:      req.OriginAccountID,
+			DestinationAccountID: req.DestinationAccountID,
+			Amount:               req.Amount.String(),
+			Currency:             req.Currency,
+			ExpiresIn:            req.ExpiresIn,
+			Status:               constant.BatchItemPending,
+		}
+
+		if serr := validateBatchItem(s.db, req, s.expiry, ctx); serr != nil {
+			itemErrors = append(itemErrors, model.TransferBatchItemError{Index: i, Error: serr.Message})
+			batchItems[i].Status = constant.BatchItemFailed
+			batchItems[i].Error = serr.Message
+		}
+	}
+
+	if len(itemErrors) > 0 && mode == constant.BatchModeAllOrNothing {
+		log.Warnw("Batch rejected: Invalid items", "invalid", len(itemErrors), "total", len(items))
+		return model.TransferBatchResponse{}, itemErrors, &ServiceError{Message: "Batch has invalid items", Code: http.StatusUnprocessableEntity}
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Create(&batch).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Batch failed: Unable to create batch", "error", err)
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: "Unable to create batch", Code: http.StatusInternalServerError, Error: err}
+	}
+	for i := range batchItems {
+		batchItems[i].BatchID = batch.ID
+	}
+	if err := tx.CreateInBatches(&batchItems, 500).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Batch failed: Unable to store items", "error", err)
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: "Unable to create batch", Code: http.StatusInternalServerError, Error: err}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorw("Batch failed: Unable to commit batch", "error", err)
+		return model.TransferBatchResponse{}, nil, &ServiceError{Message: "Unable to create batch", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	log.Infow("Batch submitted", "batch_id", batch.ID, "mode", mode, "total", len(items), "invalid", len(itemErrors))
+
+	// With every worker busy the batch stays pending until
+	// CronResumeBatches picks it up. The request context is recycled once
+	// the response is written.
+	select {
+	case s.workers <- struct{}{}:
+		go func(ctx *gin.Context) {
+			defer func() { <-s.workers }()
+			s.run(batch.ID, ctx)
+		}(ctx.Copy())
+	default:
+		log.Infow("Batch queued: All workers busy", "batch_id", batch.ID)
+	}
+
+	response := model.TransferBatchResponse{
+		ID:        batch.ID,
+		Mode:      batch.Mode,
+		Status:    batch.Status,
+		Total:     batch.TotalItems,
+		Pending:   batch.TotalItems - len(itemErrors),
+		Failed:    len(itemErrors),
+		CreatedAt: batch.CreatedAt,
+	}
+	return response, itemErrors, nil
+}
+
+// validateBatchItem runs the checks CreateTransfer would, without writing
+// anything. Quotes expire too quickly to be used from a batch, so items must
+// stay within one currency.
+func validateBatchItem(db *gorm.DB, req *model.TransferRequest, expiry time.Duration, ctx *gin.Context) *ServiceError {
+	if req.QuoteID != "" {
+		return &ServiceError{Message: "Quotes are not supported in batches", Code: http.StatusBadRequest}
+	}
+	transfer, _, serr := prepareTransfer(db, req, expiry, ctx)
+	if serr != nil {
+		return serr
+	}
+	if transfer.DestinationCurrency != transfer.Currency {
+		return &ServiceError{Message: "Cross-currency transfers are not supported in batches", Code: http.StatusUnprocessableEntity}
+	}
+	return nil
+}
+
+// GetBatch reports a batch's progress with the outcome of every item.
+func (s *transferBatchService) GetBatch(batchID string, ctx *gin.Context) (model.TransferBatchResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	id, err := strconv.ParseUint(batchID, 10, 64)
+	if err != nil {
+		return model.TransferBatchResponse{}, &ServiceError{Message: "Batch not found", Code: http.StatusNotFound}
+	}
+
+	var batch model.TransferBatch
+	if err := s.db.First(&batch, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.TransferBatchResponse{}, &ServiceError{Message: "Batch not found", Code: http.StatusNotFound}
+		}
+		log.Errorw("Failed to load batch", "batch_id", batchID, "error", err)
+		return model.TransferBatchResponse{}, &ServiceError{Message: "Failed to load batch", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	principal, _ := auth.PrincipalFrom(ctx)
+	if !principal.HasScope(constant.ScopeTransfersReadAny) && !auth.OwnsAccount(ctx, fmt.Sprint(batch.AccountID)) {
+		log.Warnw("Forbidden batch read", "batch_id", batch.ID, "caller", principal.Subject)
+		return model.TransferBatchResponse{}, &ServiceError{Message: "Forbidden", Code: http.StatusForbidden}
+	}
+
+	var items []model.TransferBatchItem
+	if err := s.db.Where("batch_id = ?", batch.ID).Order("position").Find(&items).Error; err != nil {
+		log.Errorw("Failed to load batch items", "batch_id", batch.ID, "error", err)
+		return model.TransferBatchResponse{}, &ServiceError{Message: "Failed to load batch", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	response := model.TransferBatchResponse{
+		ID:          batch.ID,
+		Mode:        batch.Mode,
+		Status:      batch.Status,
+		Total:       batch.TotalItems,
+		CreatedAt:   batch.CreatedAt,
+		CompletedAt: batch.CompletedAt,
+		Items:       make([]model.TransferBatchItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		switch item.Status {
+		case constant.BatchItemSucceeded:
+			response.Succeeded++
+		case constant.BatchItemFailed:
+			response.Failed++
+		default:
+			response.Pending++
+		}
+		response.Items = append(response.Items, item.ToResponse())
+	}
+	return response, nil
+}
+
+// CronResumeBatches picks up batches that no worker is processing: ones whose
+// worker never started, and ones whose worker stopped renewing its claim. It
+// stops between items once jobCtx is done, handing the batch back.
+func (s *transferBatchService) CronResumeBatches(jobCtx context.Context) *ServiceError {
+	now := time.Now().UTC()
+
+	var batches []model.TransferBatch
+	err := s.db.Where("(status = ? AND created_at < ?) OR (status = ? AND claimed_at < ?)",
+		constant.BatchStatusPending, now.Add(-time.Minute), constant.BatchStatusProcessing, now.Add(-batchClaimTTL)).
+		Find(&batches).Error
+	if err != nil {
+		return &ServiceError{Message: "Failed to load abandoned batches", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	for _, batch := range batches {
+		if jobCtx.Err() != nil {
+			return nil
+		}
+		log.Println("[Cron] Resuming transfer batch", batch.ID)
+		if err := s.process(jobCtx, batch.ID, actingAs(batch.AccountID, fmt.Sprintf("batch:%d", batch.ID))); err != nil {
+			if jobCtx.Err() != nil {
+				return nil
+			}
+			return &ServiceError{Message: "Failed to process batch", Code: http.StatusInternalServerError, Error: err}
+		}
+	}
+	return nil
+}
+
+func (s *transferBatchService) run(batchID uint, ctx *gin.Context) {
+	if err := s.process(context.Background(), batchID, ctx); err != nil {
+		logger.From(ctx).Errorw("Batch processing stopped", "batch_id", batchID, "error", err)
+	}
+}
+
+// process claims the batch, creates the transfers of its pending items and
+// records the outcome. It returns without doing anything if another worker
+// holds a live claim on the batch.
+func (s *transferBatchService) process(jobCtx context.Context, batchID uint, ctx *gin.Context) error {
+	claimed, err := s.claim(batchID)
+	if err != nil || !claimed {
+		return err
+	}
+
+	var batch model.TransferBatch
+	if err := s.db.First(&batch, batchID).Error; err != nil {
+		return err
+	}
+
+	if batch.Mode == constant.BatchModeAllOrNothing {
+		err = s.processAtomically(&batch, ctx)
+	} else {
+		err = s.processEach(jobCtx, &batch, ctx)
+	}
+	if err != nil {
+		return err
+	}
+	return s.finish(&batch, ctx)
+}
+
+// claim takes the batch on if it is pending or its last claim went stale.
+func (s *transferBatchService) claim(batchID uint) (bool, error) {
+	now := time.Now().UTC()
+	result := s.db.Model(&model.TransferBatch{}).
+		Where("id = ? AND (status = ? OR (status = ? AND claimed_at < ?))",
+			batchID, constant.BatchStatusPending, constant.BatchStatusProcessing, now.Add(-batchClaimTTL)).
+		Updates(map[string]interface{}{"status": constant.BatchStatusProcessing, "claimed_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+// processAtomically creates every item's transfer in one transaction. The
+// first item that fails rolls them all back; it keeps its error and the
+// others are failed with a pointer to it.
+func (s *transferBatchService) processAtomically(batch *model.TransferBatch, ctx *gin.Context) error {
+	log := logger.From(ctx)
+
+	var items []model.TransferBatchItem
+	if err := s.db.Where("batch_id = ?", batch.ID).Order("position").Find(&items).Error; err != nil {
+		return err
+	}
+
+	tx := s.db.Begin()
+
+	// Holding the batch row keeps a second worker from claiming it until
+	// this transaction ends.
+	var locked model.TransferBatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, batch.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if locked.Status != constant.BatchStatusProcessing {
+		tx.Rollback()
+		return nil
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Status != constant.BatchItemPending {
+			continue
+		}
+		transferID, serr := createBatchTransfer(tx, item, s.expiry, ctx)
+		if serr != nil {
+			tx.Rollback()
+			log.Warnw("Batch rolled back", "batch_id", batch.ID, "index", item.Position, "error", serr.Message)
+			return s.failAll(batch, item, serr.Message)
+		}
+		if err := tx.Model(item).Updates(map[string]interface{}{"status": constant.BatchItemSucceeded, "transfer_id": transferID}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// failAll records that an all-or-nothing batch was rolled back because of
+// failed.
+func (s *transferBatchService) failAll(batch *model.TransferBatch, failed *model.TransferBatchItem, reason string) error {
+	tx := s.db.Begin()
+	err := tx.Model(&model.TransferBatchItem{}).Where("id = ?", failed.ID).
+		Updates(map[string]interface{}{"status": constant.BatchItemFailed, "error": reason}).Error
+	if err == nil {
+		err = tx.Model(&model.TransferBatchItem{}).Where("batch_id = ? AND id <> ?", batch.ID, failed.ID).
+			Updates(map[string]interface{}{
+				"status":      constant.BatchItemFailed,
+				"transfer_id": nil,
+				"error":       fmt.Sprintf("Not created: item %d failed", failed.Position),
+			}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// processEach creates each pending item's transfer in its own transaction,
+// together with the item's outcome, so an item is never created twice even
+// if the batch is resumed. Once jobCtx is done it stops and gives up its
+// claim, so another worker can resume the batch straight away.
+func (s *transferBatchService) processEach(jobCtx context.Context, batch *model.TransferBatch, ctx *gin.Context) error {
+	var items []model.TransferBatchItem
+	err := s.db.Where("batch_id = ? AND status = ?", batch.ID, constant.BatchItemPending).
+		Order("position").Find(&items).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+
+		if err := jobCtx.Err(); err != nil {
+			if rerr := s.db.Model(batch).Update("claimed_at", time.Time{}).Error; rerr != nil {
+				return rerr
+			}
+			return err
+		}
+
+		if i > 0 && i%batchHeartbeatEvery == 0 {
+			if err := s.db.Model(batch).Update("claimed_at", time.Now().UTC()).Error; err != nil {
+				return err
+			}
+		}
+
+		tx := s.db.Begin()
+		transferID, serr := createBatchTransfer(tx, item, s.expiry, ctx)
+		if serr != nil {
+			tx.Rollback()
+			err := s.db.Model(&model.TransferBatchItem{}).Where("id = ? AND status = ?", item.ID, constant.BatchItemPending).
+				Updates(map[string]interface{}{"status": constant.BatchItemFailed, "error": serr.Message}).Error
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		result := tx.Model(&model.TransferBatchItem{}).Where("id = ? AND status = ?", item.ID, constant.BatchItemPending).
+			Updates(map[string]interface{}{"status": constant.BatchItemSucceeded, "transfer_id": transferID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Another worker got to it first.
+			tx.Rollback()
+			if result.Error != nil {
+				return result.Error
+			}
+			continue
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createBatchTransfer(tx *gorm.DB, item *model.TransferBatchItem, expiry time.Duration, ctx *gin.Context) (uint, *ServiceError) {
+	req := item.ToRequest()
+	transfer, destination, serr := prepareTransfer(tx, &req, expiry, ctx)
+	if serr != nil {
+		return 0, serr
+	}
+	if serr := insertTransfer(tx, &transfer, &destination, "", ctx); serr != nil {
+		return 0, serr
+	}
+	return transfer.ID, nil
+}
+
+// finish settles the batch's status from its items' outcomes.
+func (s *transferBatchService) finish(batch *model.TransferBatch, ctx *gin.Context) error {
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	err := s.db.Model(&model.TransferBatchItem{}).Select("status, COUNT(*) AS count").
+		Where("batch_id = ?", batch.ID).Group("status").Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	succeeded, failed := 0, 0
+	for _, c := range counts {
+		switch c.Status {
+		case constant.BatchItemSucceeded:
+			succeeded = c.Count
+		case constant.BatchItemFailed:
+			failed = c.Count
+		}
+	}
+	if succeeded+failed < batch.TotalItems {
+		// Items were left pending by a concurrent worker; it will finish.
+		return nil
+	}
+
+	status := constant.BatchStatusPartiallyCompleted
+	switch {
+	case failed == 0:
+		status = constant.BatchStatusCompleted
+	case succeeded == 0:
+		status = constant.BatchStatusFailed
+	}
+
+	now := time.Now().UTC()
+	err = s.db.Model(batch).Updates(map[string]interface{}{"status": status, "completed_at": now}).Error
+	if err == nil {
+		logger.From(ctx).Infow("Batch processed", "batch_id", batch.ID, "status", status, "succeeded", succeeded, "failed", failed)
+	}
+	return err
+}
+
+// ParseTransferBatchCSV reads batch items from CSV with a header row naming
+// the columns: origin_account_id, destination_account_id and amount are
+// required, currency and expires_in are optional.
+func ParseTransferBatchCSV(r io.Reader) ([]model.TransferRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"origin_account_id", "destination_account_id", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var items []model.TransferRequest
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		origin, err := strconv.ParseUint(field(record, "origin_account_id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid origin_account_id", line)
+		}
+		destination, err := strconv.ParseUint(field(record, "destination_account_id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid destination_account_id", line)
+		}
+		expiresIn := 0
+		if value := field(record, "expires_in"); value != "" {
+			if expiresIn, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid expires_in", line)
+			}
+		}
+
+		items = append(items, model.TransferRequest{
+			OriginAccountID:      uint(origin),
+			DestinationAccountID: uint(destination),
+			Amount:               json.Number(field(record, "amount")),
+			Currency:             field(record, "currency"),
+			ExpiresIn:            expiresIn,
+		})
+	}
+}
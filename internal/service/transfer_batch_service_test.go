@@ -0,0 +1,200 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"payment-service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupBatchTestDB() *gorm.DB {
+	db := setupTransferTestDB()
+	if err := db.AutoMigrate(&model.TransferBatch{}, &model.TransferBatchItem{}); err != nil {
+		panic(err)
+	}
+	// Batches are processed in the background; every connection to
+	// :memory: would otherwise get its own empty database.
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+// waitForBatch polls until the background worker has finished the batch.
+func waitForBatch(t *testing.T, batchService service.TransferBatchService, batchID uint) model.TransferBatchResponse {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		batch, err := batchService.GetBatch(fmt.Sprint(batchID), authedContext(1))
+		assert.Nil(t, err)
+		if batch.CompletedAt != nil || time.Now().After(deadline) {
+			return batch
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func batchItem(destination uint, amount string) model.TransferRequest {
+	return model.TransferRequest{OriginAccountID: 1, DestinationAccountID: destination, Amount: json.Number(amount)}
+}
+
+func TestSubmitBatch_AllOrNothingCompletes(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	items := []model.TransferRequest{batchItem(2, "10.00"), batchItem(2, "20.00"), batchItem(2, "30.00")}
+	submitted, itemErrors, err := batchService.SubmitBatch("", items, authedContext(1))
+	assert.Nil(t, err)
+	assert.Empty(t, itemErrors)
+	assert.Equal(t, constant.BatchModeAllOrNothing, submitted.Mode)
+	assert.Equal(t, 3, submitted.Total)
+
+	batch := waitForBatch(t, batchService, submitted.ID)
+	assert.Equal(t, constant.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, 3, batch.Succeeded)
+	for _, item := range batch.Items {
+		assert.Equal(t, constant.BatchItemSucceeded, item.Status)
+		assert.NotNil(t, item.TransferID)
+	}
+
+	var origin model.Account
+	db.First(&origin, 1)
+	assert.Equal(t, money.Amount(6000), origin.HeldBalance)
+}
+
+func TestSubmitBatch_AllOrNothingRejectsInvalidItems(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	items := []model.TransferRequest{batchItem(2, "10.00"), batchItem(99, "10.00"), batchItem(3, "10.00"), batchItem(2, "0.001")}
+	_, itemErrors, err := batchService.SubmitBatch(constant.BatchModeAllOrNothing, items, authedContext(1))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+	assert.Len(t, itemErrors, 3)
+	assert.Equal(t, 1, itemErrors[0].Index)
+	assert.Equal(t, "Destination account not found", itemErrors[0].Error)
+	assert.Equal(t, "Cross-currency transfers are not supported in batches", itemErrors[1].Error)
+	assert.Equal(t, 3, itemErrors[2].Index)
+
+	var count int64
+	db.Model(&model.TransferBatch{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestSubmitBatch_AllOrNothingSizeLimit(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	items := make([]model.TransferRequest, 501)
+	for i := range items {
+		items[i] = batchItem(2, "0.01")
+	}
+	_, _, err := batchService.SubmitBatch(constant.BatchModeAllOrNothing, items, authedContext(1))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	var count int64
+	db.Model(&model.TransferBatch{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestSubmitBatch_AllOrNothingRollsBack(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	// Each fits the 100.00 balance on its own, but not together.
+	items := []model.TransferRequest{batchItem(2, "60.00"), batchItem(2, "60.00")}
+	submitted, _, err := batchService.SubmitBatch(constant.BatchModeAllOrNothing, items, authedContext(1))
+	assert.Nil(t, err)
+
+	batch := waitForBatch(t, batchService, submitted.ID)
+	assert.Equal(t, constant.BatchStatusFailed, batch.Status)
+	assert.Equal(t, 2, batch.Failed)
+	assert.Equal(t, "Not created: item 1 failed", batch.Items[0].Error)
+	assert.Nil(t, batch.Items[0].TransferID)
+	assert.Equal(t, "Insufficient funds", batch.Items[1].Error)
+
+	var transfers int64
+	db.Model(&model.Transfer{}).Count(&transfers)
+	assert.Equal(t, int64(0), transfers)
+	var origin model.Account
+	db.First(&origin, 1)
+	assert.Equal(t, money.Amount(0), origin.HeldBalance)
+}
+
+func TestSubmitBatch_BestEffort(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	items := []model.TransferRequest{batchItem(2, "60.00"), batchItem(99, "1.00"), batchItem(2, "60.00")}
+	submitted, itemErrors, err := batchService.SubmitBatch("best_effort", items, authedContext(1))
+	assert.Nil(t, err)
+	assert.Len(t, itemErrors, 1)
+	assert.Equal(t, 1, submitted.Failed)
+
+	batch := waitForBatch(t, batchService, submitted.ID)
+	assert.Equal(t, constant.BatchStatusPartiallyCompleted, batch.Status)
+	assert.Equal(t, 1, batch.Succeeded)
+	assert.Equal(t, 2, batch.Failed)
+	assert.Equal(t, constant.BatchItemSucceeded, batch.Items[0].Status)
+	assert.Equal(t, "Destination account not found", batch.Items[1].Error)
+	assert.Equal(t, "Insufficient funds", batch.Items[2].Error)
+
+	_, serr := batchService.GetBatch(fmt.Sprint(submitted.ID), authedContext(2))
+	assert.Equal(t, http.StatusForbidden, serr.Code)
+}
+
+func TestCronResumeBatches_PicksUpAbandonedBatch(t *testing.T) {
+	db := setupBatchTestDB()
+	batchService := service.NewTransferBatchService(db, 5*time.Minute)
+	logger.Init("test")
+
+	stale := time.Now().UTC().Add(-time.Hour)
+	batch := model.TransferBatch{AccountID: 1, Mode: constant.BatchModeBestEffort, Status: constant.BatchStatusProcessing, TotalItems: 2, ClaimedAt: &stale}
+	db.Create(&batch)
+	transferID := uint(0)
+	db.Create(&model.TransferBatchItem{BatchID: batch.ID, Position: 0, OriginAccountID: 1, DestinationAccountID: 2, Amount: "5.00", Status: constant.BatchItemSucceeded, TransferID: &transferID})
+	db.Create(&model.TransferBatchItem{BatchID: batch.ID, Position: 1, OriginAccountID: 1, DestinationAccountID: 2, Amount: "7.00", Status: constant.BatchItemPending})
+
+	assert.Nil(t, batchService.CronResumeBatches(t.Context()))
+
+	result, serr := batchService.GetBatch(fmt.Sprint(batch.ID), authedContext(1))
+	assert.Nil(t, serr)
+	assert.Equal(t, constant.BatchStatusCompleted, result.Status)
+	assert.Equal(t, 2, result.Succeeded)
+
+	// Only the pending item was created again.
+	var transfers []model.Transfer
+	db.Find(&transfers)
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, money.Amount(700), transfers[0].Amount)
+}
+
+func TestParseTransferBatchCSV(t *testing.T) {
+	items, err := service.ParseTransferBatchCSV(strings.NewReader("origin_account_id,destination_account_id,amount,currency\n1,2,10.50,USD\n1, 3, 7,\n"))
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, uint(2), items[0].DestinationAccountID)
+	assert.Equal(t, "10.50", items[0].Amount.String())
+	assert.Equal(t, "USD", items[0].Currency)
+	assert.Equal(t, uint(3), items[1].DestinationAccountID)
+
+	_, err = service.ParseTransferBatchCSV(strings.NewReader("origin_account_id,amount\n1,10\n"))
+	assert.ErrorContains(t, err, "destination_account_id")
+
+	_, err = service.ParseTransferBatchCSV(strings.NewReader("origin_account_id,destination_account_id,amount\nx,2,10\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
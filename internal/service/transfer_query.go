@@ -0,0 +1,194 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+const (
+	directionIn  = "in"
+	directionOut = "out"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// ListTransfers pages through transfers newest first. With an account, only
+// its incoming and outgoing transfers are listed. Pages are keyed on the
+// transfer ID, so rows inserted while paging never shift later pages.
+func (s *transferService) ListTransfers(query *model.TransferListQuery, ctx *gin.Context) (model.TransferListResponse, *ServiceError) {
+	log := logger.From(ctx)
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return model.TransferListResponse{}, &ServiceError{Message: "limit must be between 1 and " + strconv.Itoa(maxListLimit), Code: http.StatusBadRequest}
+	}
+
+	db := s.db.Model(&model.Transfer{})
+
+	var account *model.Account
+	if query.AccountID != "" {
+		account = &model.Account{}
+		if err := s.db.First(account, "id = ?", query.AccountID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.TransferListResponse{}, &ServiceError{Message: "Account not found", Code: http.StatusNotFound}
+			}
+			log.Errorw("Failed to load account", "account_id", query.AccountID, "error", err)
+			return model.TransferListResponse{}, &ServiceError{Message: "Failed to list transfers", Code: http.StatusInternalServerError, Error: err}
+		}
+	}
+
+	db, serr := filterByAccount(db, account, query)
+	if serr != nil {
+		return model.TransferListResponse{}, serr
+	}
+
+	if query.Status != "" {
+		statuses := strings.Split(strings.ToUpper(query.Status), ",")
+		for _, status := range statuses {
+			if !isTransferStatus(status) {
+				return model.TransferListResponse{}, &ServiceError{Message: "Invalid status " + status, Code: http.StatusBadRequest}
+			}
+		}
+		db = db.Where("status IN ?", statuses)
+	}
+
+	if query.CreatedFrom != "" {
+		from, err := time.Parse(time.RFC3339, query.CreatedFrom)
+		if err != nil {
+			return model.TransferListResponse{}, &ServiceError{Message: "created_from must be an RFC 3339 timestamp", Code: http.StatusBadRequest, Error: err}
+		}
+		db = db.Where("created_at >= ?", from)
+	}
+	if query.CreatedTo != "" {
+		to, err := time.Parse(time.RFC3339, query.CreatedTo)
+		if err != nil {
+			return model.TransferListResponse{}, &ServiceError{Message: "created_to must be an RFC 3339 timestamp", Code: http.StatusBadRequest, Error: err}
+		}
+		db = db.Where("created_at < ?", to)
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return model.TransferListResponse{}, &ServiceError{Message: "Invalid cursor", Code: http.StatusBadRequest, Error: err}
+		}
+		db = db.Where("id < ?", after)
+	}
+
+	var transfers []model.Transfer
+	if err := db.Order("id DESC").Limit(limit + 1).Find(&transfers).Error; err != nil {
+		log.Errorw("Failed to list transfers", "error", err)
+		return model.TransferListResponse{}, &ServiceError{Message: "Failed to list transfers", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	response := model.TransferListResponse{Result: make([]model.TransferResponse, 0, len(transfers))}
+	if len(transfers) > limit {
+		transfers = transfers[:limit]
+		response.NextCursor = encodeCursor(transfers[limit-1].ID)
+	}
+	for _, transfer := range transfers {
+		response.Result = append(response.Result, transfer.ToResponse())
+	}
+	return response, nil
+}
+
+// filterByAccount restricts the listing to the account's transfers and
+// applies the direction and amount filters. Outgoing amounts are compared on
+// amount and incoming ones on destination_amount, so both are in the
+// account's currency.
+func filterByAccount(db *gorm.DB, account *model.Account, query *model.TransferListQuery) (*gorm.DB, *ServiceError) {
+	direction := strings.ToLower(query.Direction)
+	if direction != "" && direction != directionIn && direction != directionOut {
+		return nil, &ServiceError{Message: "direction must be in or out", Code: http.StatusBadRequest}
+	}
+
+	if account == nil {
+		if direction != "" {
+			return nil, &ServiceError{Message: "direction requires an account", Code: http.StatusBadRequest}
+		}
+		if query.Currency != "" {
+			db = db.Where("currency = ?", strings.ToUpper(query.Currency))
+		}
+		if query.MinAmount == "" && query.MaxAmount == "" {
+			return db, nil
+		}
+		if query.Currency == "" {
+			return nil, &ServiceError{Message: "Amount filters require a currency", Code: http.StatusBadRequest}
+		}
+		return filterByAmount(db, "amount", money.CurrencyFor(query.Currency), query)
+	}
+
+	var outgoing, incoming *gorm.DB
+	var serr *ServiceError
+	currency := money.CurrencyFor(account.Currency)
+	if direction != directionIn {
+		if outgoing, serr = filterByAmount(db.Session(&gorm.Session{NewDB: true}).Where("origin_account_id = ?", account.ID), "amount", currency, query); serr != nil {
+			return nil, serr
+		}
+	}
+	if direction != directionOut {
+		if incoming, serr = filterByAmount(db.Session(&gorm.Session{NewDB: true}).Where("destination_account_id = ?", account.ID), "destination_amount", currency, query); serr != nil {
+			return nil, serr
+		}
+	}
+
+	switch {
+	case outgoing == nil:
+		return db.Where(incoming), nil
+	case incoming == nil:
+		return db.Where(outgoing), nil
+	}
+	return db.Where(outgoing.Or(incoming)), nil
+}
+
+func filterByAmount(db *gorm.DB, column string, currency money.Currency, query *model.TransferListQuery) (*gorm.DB, *ServiceError) {
+	if query.MinAmount != "" {
+		min, err := money.Parse(query.MinAmount, currency)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid min_amount: " + err.Error(), Code: http.StatusBadRequest, Error: err}
+		}
+		db = db.Where(column+" >= ?", min)
+	}
+	if query.MaxAmount != "" {
+		max, err := money.Parse(query.MaxAmount, currency)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid max_amount: " + err.Error(), Code: http.StatusBadRequest, Error: err}
+		}
+		db = db.Where(column+" <= ?", max)
+	}
+	return db, nil
+}
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	return id, nil
+}
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// seedTransfers creates transfers 1→2 of 1.00..5.00 and one 2→1 of 7.00,
+// oldest first.
+func seedTransfers(t *testing.T, db *gorm.DB) service.TransferService {
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	for _, amount := range []string{"1.00", "2.00", "3.00", "4.00", "5.00"} {
+		_, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: json.Number(amount)}, authedContext(1))
+		assert.Nil(t, err)
+	}
+	_, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 2, DestinationAccountID: 1, Amount: "7.00"}, authedContext(2))
+	assert.Nil(t, err)
+	return transferService
+}
+
+func TestListTransfers_PagesWithCursor(t *testing.T) {
+	db := setupTransferTestDB()
+	logger.Init("test")
+	transferService := seedTransfers(t, db)
+
+	ctx := &gin.Context{}
+
+	first, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", Limit: 4}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, first.Result, 4)
+	assert.Equal(t, "7.00", first.Result[0].Amount)
+	assert.NotEmpty(t, first.NextCursor)
+
+	second, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", Limit: 4, Cursor: first.NextCursor}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, second.Result, 2)
+	assert.Equal(t, "2.00", second.Result[0].Amount)
+	assert.Equal(t, "1.00", second.Result[1].Amount)
+	assert.Empty(t, second.NextCursor)
+
+	_, err = transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", Cursor: "%%%"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+}
+
+func TestListTransfers_Filters(t *testing.T) {
+	db := setupTransferTestDB()
+	logger.Init("test")
+	transferService := seedTransfers(t, db)
+
+	ctx := &gin.Context{}
+
+	incoming, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", Direction: "in"}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, incoming.Result, 1)
+	assert.Equal(t, uint(2), incoming.Result[0].OriginAccountID)
+
+	ranged, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", Direction: "out", MinAmount: "2", MaxAmount: "4.00"}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, ranged.Result, 3)
+
+	// Without a direction the amount range applies to both sides.
+	both, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "1", MinAmount: "4.50"}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, both.Result, 2)
+
+	db.Model(&model.Transfer{}).Where("id = ?", 1).Update("status", constant.TransferStatusFailed)
+	failed, err := transferService.ListTransfers(&model.TransferListQuery{Status: "failed,expired"}, ctx)
+	assert.Nil(t, err)
+	assert.Len(t, failed.Result, 1)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	none, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "2", CreatedFrom: future}, ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, none.Result)
+}
+
+func TestListTransfers_InvalidQueries(t *testing.T) {
+	db := setupTransferTestDB()
+	logger.Init("test")
+	transferService := seedTransfers(t, db)
+
+	ctx := &gin.Context{}
+
+	queries := []model.TransferListQuery{
+		{Direction: "in"},
+		{MinAmount: "1.00"},
+		{AccountID: "1", Direction: "sideways"},
+		{AccountID: "1", MinAmount: "1.001"},
+		{Status: "LOST"},
+		{CreatedFrom: "yesterday"},
+		{Limit: 1000},
+	}
+	for _, query := range queries {
+		_, err := transferService.ListTransfers(&query, ctx)
+		assert.NotNil(t, err, "%+v", query)
+		assert.Equal(t, http.StatusBadRequest, err.Code)
+	}
+
+	_, err := transferService.ListTransfers(&model.TransferListQuery{AccountID: "999"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
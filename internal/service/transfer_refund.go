@@ -0,0 +1,275 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundTransfer sends all or part of a completed transfer back to its
+// origin as a new transfer linked through refund_of_id. Refunds settle
+// immediately, and a transfer can be refunded several times until the whole
+// amount has gone back; the last refund moves it to REVERSED. It returns the
+// refund and the updated original.
+func (s *transferService) RefundTransfer(transferID string, req *model.TransferRefundRequest, ctx *gin.Context) (model.Transfer, model.Transfer, *ServiceError) {
+	log := logger.From(ctx)
+
+	original, serr := s.findTransfer(transferID, ctx)
+	if serr != nil {
+		return model.Transfer{}, model.Transfer{}, serr
+	}
+
+	// Refunds are paid by the account that received the money.
+	principal, _ := auth.PrincipalFrom(ctx)
+	if !principal.HasScope(constant.ScopeAdmin) && !auth.OwnsAccount(ctx, fmt.Sprint(original.DestinationAccountID)) {
+		log.Warnw("Refund failed: Caller does not own the destination account", "transfer_id", original.ID, "caller", principal.Subject)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Forbidden", Code: http.StatusForbidden}
+	}
+
+	tx := s.db.Begin()
+
+	// Lock the original first so concurrent refunds of it queue up and each
+	// sees what the previous one refunded.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, original.ID).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Transfer not found", "transfer_id", original.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Transfer not found", Code: http.StatusNotFound}
+	}
+
+	if original.RefundOfID != nil {
+		tx.Rollback()
+		log.Warnw("Refund failed: Transfer is itself a refund", "transfer_id", original.ID)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "A refund cannot be refunded", Code: http.StatusUnprocessableEntity}
+	}
+
+	if original.Status != constant.TransferStatusCompleted {
+		tx.Rollback()
+		log.Warnw("Refund failed: Transfer is not completed", "transfer_id", original.ID, "status", original.Status)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Only completed transfers can be refunded", Code: http.StatusConflict}
+	}
+
+	remaining, err := original.Amount.Sub(original.RefundedAmount)
+	if err != nil || remaining <= 0 {
+		tx.Rollback()
+		log.Warnw("Refund failed: Nothing left to refund", "transfer_id", original.ID)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Transfer has already been fully refunded", Code: http.StatusConflict}
+	}
+
+	amount := remaining
+	if req.Amount != "" {
+		amount, err = money.Parse(req.Amount.String(), money.CurrencyFor(original.Currency))
+		if err != nil {
+			tx.Rollback()
+			log.Errorw("Refund failed: Invalid amount", "amount", req.Amount, "error", err)
+			return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Invalid refund amount: " + err.Error(), Code: http.StatusBadRequest, Error: err}
+		}
+		if amount <= 0 {
+			tx.Rollback()
+			log.Errorw("Refund failed: Amount must be greater than zero")
+			return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Invalid refund amount", Code: http.StatusBadRequest}
+		}
+		if amount > remaining {
+			tx.Rollback()
+			log.Warnw("Refund failed: Amount exceeds what is left to refund", "transfer_id", original.ID, "amount", amount, "remaining", remaining)
+			return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Refund amount exceeds the amount left to refund", Code: http.StatusUnprocessableEntity}
+		}
+	}
+
+	debit, serr := refundDebit(tx, &original, amount, amount == remaining, ctx)
+	if serr != nil {
+		tx.Rollback()
+		return model.Transfer{}, model.Transfer{}, serr
+	}
+	if debit <= 0 {
+		tx.Rollback()
+		log.Warnw("Refund failed: Amount converts to nothing", "transfer_id", original.ID, "amount", amount)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Refund amount is too small to convert to " + original.DestinationCurrency, Code: http.StatusUnprocessableEntity}
+	}
+
+	// The refund converts back at the original rate, so it records the
+	// inverse.
+	fxRate := ""
+	if rate, err := money.ParseRate(original.FXRate); err == nil {
+		if inverse, err := rate.Inverse(); err == nil {
+			fxRate = inverse.String()
+		}
+	}
+
+	refund := model.Transfer{
+		OriginAccountID:      original.DestinationAccountID,
+		DestinationAccountID: original.OriginAccountID,
+		Amount:               debit,
+		Currency:             original.DestinationCurrency,
+		DestinationAmount:    amount,
+		DestinationCurrency:  original.Currency,
+		FXRate:               fxRate,
+		Status:               constant.TransferStatusPending,
+		RefundOfID:           &original.ID,
+	}
+
+	// The refund runs the other way to the original, so a refund and a
+	// completion between the same accounts must lock them in the same,
+	// ID-based order.
+	originAccount, destinationAccount, missing, err := lockAccounts(tx, refund.OriginAccountID, refund.DestinationAccountID)
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: "+missing+" account not found", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: missing + " account not found", Code: http.StatusNotFound}
+	}
+
+	if serr := requireActiveAccounts(&originAccount, &destinationAccount, ctx); serr != nil {
+		tx.Rollback()
+		return model.Transfer{}, model.Transfer{}, serr
+	}
+
+	if originAccount.AvailableBalance() < refund.Amount {
+		tx.Rollback()
+		log.Errorw("Refund failed: Insufficient funds", "account_id", originAccount.ID, "amount", refund.Amount)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Insufficient funds", Code: http.StatusBadRequest}
+	}
+
+	originBalance, err := originAccount.Balance.Sub(refund.Amount)
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Origin balance out of range", "transfer_id", original.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Origin balance out of range", Code: http.StatusUnprocessableEntity, Error: err}
+	}
+
+	destinationBalance, err := destinationAccount.Balance.Add(refund.DestinationAmount)
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Destination balance out of range", "transfer_id", original.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Destination balance out of range", Code: http.StatusUnprocessableEntity, Error: err}
+	}
+
+	originAccount.Balance = originBalance
+	destinationAccount.Balance = destinationBalance
+
+	if err := tx.Save(&originAccount).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to update origin account", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to update origin account", Code: http.StatusInternalServerError}
+	}
+
+	if err := tx.Save(&destinationAccount).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to update destination account", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to update destination account", Code: http.StatusInternalServerError}
+	}
+
+	actor := actorFrom(ctx)
+	reason := req.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("refund of transfer %d", original.ID)
+	}
+
+	if err := tx.Create(&refund).Error; err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to create refund", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError}
+	}
+
+	if err := recordInitialStatus(tx, &refund, actor); err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to record status history", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError}
+	}
+
+	if err := transitionTransfer(tx, &refund, constant.TransferStatusCompleted, actor, reason); err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to complete refund", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError}
+	}
+
+	if err := ledger.PostTransfer(tx, &refund); err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to post ledger entries", "transfer_id", refund.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to post ledger entries", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	original.RefundedAmount += amount
+	if original.RefundedAmount == original.Amount {
+		original.RefundStatus = constant.TransferRefundFull
+		err = transitionTransfer(tx, &original, constant.TransferStatusReversed, actor, reason)
+	} else {
+		original.RefundStatus = constant.TransferRefundPartial
+		err = tx.Save(&original).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to update original transfer", "transfer_id", original.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
+	}
+
+	// The refund is a transfer of its own; the refunded event carries the
+	// original with its new refund status.
+	err = enqueueTransferEvent(tx, constant.EventTransferCompleted, &refund)
+	if err == nil {
+		err = enqueueTransferEvent(tx, constant.EventTransferRefunded, &original)
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Refund failed: Unable to record events", "transfer_id", original.ID, "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Errorw("Refund failed: Unable to commit refund", "error", err)
+		return model.Transfer{}, model.Transfer{}, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError}
+	}
+
+	log.Infow("Transfer refunded", "transfer_id", original.ID, "refund_id", refund.ID, "amount", amount, "refund_status", original.RefundStatus)
+	return refund, original, nil
+}
+
+// refundDebit works out how much to take back from the account that was
+// credited, in its currency, for a refund of amount in the original
+// currency. Partial refunds of cross-currency transfers convert at the
+// original rate; the final refund takes whatever is left of the credited
+// amount, so rounding never leaves a remainder behind. tx must hold the lock
+// on the original.
+func refundDebit(tx *gorm.DB, original *model.Transfer, amount money.Amount, final bool, ctx *gin.Context) (money.Amount, *ServiceError) {
+	log := logger.From(ctx)
+
+	if original.Currency == original.DestinationCurrency && original.Amount == original.DestinationAmount {
+		return amount, nil
+	}
+
+	if final {
+		var refunded int64
+		err := tx.Model(&model.Transfer{}).
+			Where("refund_of_id = ? AND status = ?", original.ID, constant.TransferStatusCompleted).
+			Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error
+		if err != nil {
+			log.Errorw("Refund failed: Unable to sum earlier refunds", "transfer_id", original.ID, "error", err)
+			return 0, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError, Error: err}
+		}
+		debit, err := original.DestinationAmount.Sub(money.Amount(refunded))
+		if err != nil {
+			log.Errorw("Refund failed: Refunded amount out of range", "transfer_id", original.ID, "error", err)
+			return 0, &ServiceError{Message: "Refunded amount out of range", Code: http.StatusUnprocessableEntity, Error: err}
+		}
+		return debit, nil
+	}
+
+	rate, err := money.ParseRate(original.FXRate)
+	if err != nil {
+		log.Errorw("Refund failed: Invalid rate on transfer", "transfer_id", original.ID, "fx_rate", original.FXRate, "error", err)
+		return 0, &ServiceError{Message: "Unable to create refund", Code: http.StatusInternalServerError, Error: err}
+	}
+	debit, err := rate.Convert(amount, money.CurrencyFor(original.Currency), money.CurrencyFor(original.DestinationCurrency))
+	if err != nil {
+		log.Errorw("Refund failed: Unable to convert amount", "transfer_id", original.ID, "error", err)
+		return 0, &ServiceError{Message: "Unable to create refund", Code: http.StatusUnprocessableEntity, Error: err}
+	}
+	return debit, nil
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/logger"
+	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"payment-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// completedTransfer creates and completes a transfer from account 1 to 2.
+func completedTransfer(t *testing.T, transferService service.TransferService, amount string) model.Transfer {
+	req := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: json.Number(amount)}
+	transfer, err := transferService.CreateTransfer(&req, authedContext(1))
+	assert.Nil(t, err)
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(transfer.ID), constant.TransferStatusCompleted, "", authedContext(1))
+	assert.Nil(t, err)
+	return transfer
+}
+
+func assertBalancesMatchLedger(t *testing.T, db *gorm.DB, ids ...uint) {
+	for _, id := range ids {
+		var account model.Account
+		db.First(&account, id)
+		balance, err := ledger.AccountBalance(db, id)
+		assert.NoError(t, err)
+		assert.Equal(t, account.Balance, balance, "account %d", id)
+	}
+}
+
+func TestRefundTransfer_PartialThenFull(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	transfer := completedTransfer(t, transferService, "50.00")
+
+	refund, original, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "20.00"}, authedContext(2))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), refund.OriginAccountID)
+	assert.Equal(t, uint(1), refund.DestinationAccountID)
+	assert.Equal(t, money.Amount(2000), refund.Amount)
+	assert.Equal(t, constant.TransferStatusCompleted, refund.Status)
+	assert.Equal(t, transfer.ID, *refund.RefundOfID)
+	assert.Equal(t, constant.TransferStatusCompleted, original.Status)
+	assert.Equal(t, constant.TransferRefundPartial, original.RefundStatus)
+	assert.Equal(t, "20.00", original.ToResponse().RefundedAmount)
+
+	// Without an amount, the rest is refunded.
+	refund, original, err = transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{}, authedContext(2))
+	assert.Nil(t, err)
+	assert.Equal(t, money.Amount(3000), refund.Amount)
+	assert.Equal(t, constant.TransferStatusReversed, original.Status)
+	assert.Equal(t, constant.TransferRefundFull, original.RefundStatus)
+
+	var origin, destination model.Account
+	db.First(&origin, 1)
+	db.First(&destination, 2)
+	assert.Equal(t, money.Amount(10000), origin.Balance)
+	assert.Equal(t, money.Amount(20000), destination.Balance)
+	assertBalancesMatchLedger(t, db, 1, 2)
+	assert.Equal(t, []string{constant.EventTransferCreated, constant.EventTransferCompleted, constant.EventTransferRefunded, constant.EventTransferRefunded}, outboxEventTypes(db, transfer.ID))
+	assert.Equal(t, []string{constant.EventTransferCompleted}, outboxEventTypes(db, refund.ID))
+
+	_, _, err = transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{}, authedContext(2))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+}
+
+func TestRefundTransfer_ExceedsRemaining(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	transfer := completedTransfer(t, transferService, "50.00")
+
+	_, _, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "30.00"}, authedContext(2))
+	assert.Nil(t, err)
+
+	_, _, err = transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "20.01"}, authedContext(2))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+}
+
+func TestRefundTransfer_OnlyCompletedAndOnlyByRecipient(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	pending, serr := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, authedContext(1))
+	assert.Nil(t, serr)
+
+	_, _, err := transferService.RefundTransfer(fmt.Sprint(pending.ID), &model.TransferRefundRequest{}, authedContext(2))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+
+	transfer := completedTransfer(t, transferService, "10.00")
+
+	// The sender can't refund itself.
+	_, _, err = transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{}, authedContext(1))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	refund, _, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "1.00"}, authedContext(2))
+	assert.Nil(t, err)
+
+	_, _, err = transferService.RefundTransfer(fmt.Sprint(refund.ID), &model.TransferRefundRequest{}, authedContext(1))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+}
+
+func TestRefundTransfer_CrossCurrencyLeavesNoRemainder(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	// 10.00 USD from account 1 became 9.23 EUR in account 3.
+	transfer := model.Transfer{
+		OriginAccountID:      1,
+		DestinationAccountID: 3,
+		Amount:               1000,
+		Currency:             "USD",
+		DestinationAmount:    923,
+		DestinationCurrency:  "EUR",
+		FXRate:               "0.9231",
+		Status:               constant.TransferStatusCompleted,
+	}
+	db.Create(&transfer)
+
+	var debits []money.Amount
+	for _, amount := range []string{"3.33", "3.33", "3.34"} {
+		refund, _, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: json.Number(amount)}, authedContext(3))
+		assert.Nil(t, err)
+		assert.Equal(t, "EUR", refund.Currency)
+		assert.Equal(t, "USD", refund.DestinationCurrency)
+		assert.Equal(t, "1.0833062507", refund.FXRate)
+		debits = append(debits, refund.Amount)
+	}
+
+	assert.Equal(t, []money.Amount{307, 307, 309}, debits)
+
+	var original model.Transfer
+	db.First(&original, transfer.ID)
+	assert.Equal(t, constant.TransferStatusReversed, original.Status)
+	assert.Equal(t, money.Amount(1000), original.RefundedAmount)
+}
+
+func TestRefundTransfer_TooSmallToConvert(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+	logger.Init("test")
+
+	// 10.00 USD from account 1 became 4.00 EUR in account 3.
+	transfer := model.Transfer{
+		OriginAccountID:      1,
+		DestinationAccountID: 3,
+		Amount:               1000,
+		Currency:             "USD",
+		DestinationAmount:    400,
+		DestinationCurrency:  "EUR",
+		FXRate:               "0.4",
+		Status:               constant.TransferStatusCompleted,
+	}
+	db.Create(&transfer)
+
+	// 0.01 USD is 0.004 EUR, which rounds to nothing.
+	_, _, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "0.01"}, authedContext(3))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+
+	refund, _, err := transferService.RefundTransfer(fmt.Sprint(transfer.ID), &model.TransferRefundRequest{Amount: "0.02"}, authedContext(3))
+	assert.Nil(t, err)
+	assert.Equal(t, money.Amount(1), refund.Amount)
+	assert.Equal(t, "2.5", refund.FXRate)
+}
@@ -1,11 +1,19 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/auth"
 	"payment-service/internal/middleware/logger"
 	"payment-service/internal/model"
+	"payment-service/internal/money"
+	"payment-service/internal/outbox"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +23,17 @@ import (
 
 type TransferService interface {
 	CreateTransfer(req *model.TransferRequest, ctx *gin.Context) (model.Transfer, *ServiceError)
-	UpdateTransferStatus(transferID string, status string, ctx *gin.Context) (*model.Transfer, *ServiceError)
+	UpdateTransferStatus(transferID string, status string, reason string, ctx *gin.Context) (*model.Transfer, *ServiceError)
+	GetTransfer(transferID string, ctx *gin.Context) (model.Transfer, *ServiceError)
+	GetTransferHistory(transferID string, ctx *gin.Context) ([]model.TransferStatusHistory, *ServiceError)
+	ListTransfers(query *model.TransferListQuery, ctx *gin.Context) (model.TransferListResponse, *ServiceError)
+	RefundTransfer(transferID string, req *model.TransferRefundRequest, ctx *gin.Context) (model.Transfer, model.Transfer, *ServiceError)
 	CronExpireTransfers() (*ServiceError)
 }
 
 type transferService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	expiry time.Duration
 }
 
 type ServiceError struct {
@@ -29,129 +42,626 @@ type ServiceError struct {
 	Error   error  `json:"-"`
 }
 
-func NewTransferService(db *gorm.DB) TransferService {
-	return &transferService{db: db}
+// maxTransferExpiry bounds the per-transfer expires_in a client can ask for.
+const maxTransferExpiry = 24 * time.Hour
+
+// NewTransferService creates the service; expiry is how long a transfer may
+// stay pending before the scheduler expires it, unless the request overrides it.
+func NewTransferService(db *gorm.DB, expiry time.Duration) TransferService {
+	return &transferService{db: db, expiry: expiry}
 }
 
 func (s *transferService) CreateTransfer(req *model.TransferRequest, ctx *gin.Context) (model.Transfer, *ServiceError) {
 	log := logger.From(ctx)
 
-	if req.Amount <= 0 {
-		log.Errorw("Transfer failed: Amount must be greater than zero")
-		return model.Transfer{}, &ServiceError{Message: "Invalid transfer amount", Code: http.StatusBadRequest}
+	transfer, destinationAccount, serr := prepareTransfer(s.db, req, s.expiry, ctx)
+	if serr != nil {
+		return model.Transfer{}, serr
+	}
+
+	tx := s.db.Begin()
+
+	if serr := insertTransfer(tx, &transfer, &destinationAccount, req.QuoteID, ctx); serr != nil {
+		tx.Rollback()
+		return model.Transfer{}, serr
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Errorw("Transfer failed: Unable to commit transfer", "error", err)
+		return model.Transfer{}, &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+	}
+
+	log.Infow("Transfer created successfully", "transfer", transfer)
+
+	return transfer, nil
+}
+
+// prepareTransfer validates a transfer request against the caller and the
+// accounts and builds the pending transfer, without writing anything. It
+// also returns the destination account for insertTransfer.
+func prepareTransfer(db *gorm.DB, req *model.TransferRequest, defaultExpiry time.Duration, ctx *gin.Context) (model.Transfer, model.Account, *ServiceError) {
+	log := logger.From(ctx)
+
+	if !auth.OwnsAccount(ctx, fmt.Sprint(req.OriginAccountID)) {
+		log.Warnw("Transfer failed: Caller does not own the origin account", "origin_account_id", req.OriginAccountID, "caller", auth.AccountID(ctx))
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Origin account does not belong to the caller", Code: http.StatusForbidden}
 	}
 
 	if req.OriginAccountID == req.DestinationAccountID {
 		log.Errorw("Transfer failed: From and To account IDs are the same")
-		return model.Transfer{}, &ServiceError{Message: "Cannot transfer to the same account", Code: http.StatusBadRequest}
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Cannot transfer to the same account", Code: http.StatusBadRequest}
+	}
+
+	expiry := defaultExpiry
+	if req.ExpiresIn != 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+		if expiry <= 0 || expiry > maxTransferExpiry {
+			log.Errorw("Transfer failed: Invalid expiry", "expires_in", req.ExpiresIn)
+			return model.Transfer{}, model.Account{}, &ServiceError{Message: "expires_in must be between 1 and 86400 seconds", Code: http.StatusBadRequest}
+		}
+	}
+
+	if req.Currency != "" {
+		if _, ok := money.LookupCurrency(req.Currency); !ok {
+			log.Errorw("Transfer failed: Unsupported currency", "currency", req.Currency)
+			return model.Transfer{}, model.Account{}, &ServiceError{Message: "Unsupported currency", Code: http.StatusBadRequest}
+		}
 	}
 
 	var originAccount, destinationAccount model.Account
-	if err := s.db.First(&originAccount, req.OriginAccountID).Error; err != nil {
+	if err := db.First(&originAccount, req.OriginAccountID).Error; err != nil {
 		log.Errorw("Transfer failed: Origin account not found", "error", err)
-		return model.Transfer{}, &ServiceError{Message: "Origin account not found", Code: http.StatusNotFound}
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Origin account not found", Code: http.StatusNotFound}
 	}
 
-	if err := s.db.First(&destinationAccount, req.DestinationAccountID).Error; err != nil {
+	if err := db.First(&destinationAccount, req.DestinationAccountID).Error; err != nil {
 		log.Errorw("Transfer failed: Destination account not found", "error", err)
-		return model.Transfer{}, &ServiceError{Message: "Destination account not found", Code: http.StatusNotFound}
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Destination account not found", Code: http.StatusNotFound}
+	}
+
+	if serr := requireActiveAccounts(&originAccount, &destinationAccount, ctx); serr != nil {
+		return model.Transfer{}, model.Account{}, serr
+	}
+
+	// The amount is always denominated in the origin account's currency.
+	currency := originAccount.Currency
+	if req.Currency != "" && strings.ToUpper(req.Currency) != currency {
+		log.Errorw("Transfer failed: Currency mismatch", "currency", req.Currency, "origin_currency", originAccount.Currency)
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Currency mismatch between accounts and transfer", Code: http.StatusUnprocessableEntity}
+	}
+
+	amount, err := money.Parse(req.Amount.String(), money.CurrencyFor(currency))
+	if err != nil {
+		log.Errorw("Transfer failed: Invalid amount", "amount", req.Amount, "error", err)
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Invalid transfer amount: " + err.Error(), Code: http.StatusBadRequest, Error: err}
+	}
+
+	if amount <= 0 {
+		log.Errorw("Transfer failed: Amount must be greater than zero")
+		return model.Transfer{}, model.Account{}, &ServiceError{Message: "Invalid transfer amount", Code: http.StatusBadRequest}
 	}
 
 	transfer := model.Transfer{
 		OriginAccountID:      req.OriginAccountID,
 		DestinationAccountID: req.DestinationAccountID,
-		Amount:               req.Amount,
+		Amount:               amount,
+		Currency:             currency,
+		DestinationAmount:    amount,
+		DestinationCurrency:  destinationAccount.Currency,
+		FXRate:               "1",
 		Status:               constant.TransferStatusPending,
 	}
+	expiresAt := time.Now().Add(expiry)
+	transfer.ExpiresAt = &expiresAt
+
+	return transfer, destinationAccount, nil
+}
+
+// insertTransfer applies the quote, holds the funds on the origin account
+// and stores the prepared transfer, all within tx. The caller commits.
+func insertTransfer(tx *gorm.DB, transfer *model.Transfer, destinationAccount *model.Account, quoteID string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	if destinationAccount.Currency != transfer.Currency || quoteID != "" {
+		if serr := applyQuote(tx, transfer, quoteID, ctx); serr != nil {
+			return serr
+		}
+	}
+
+	// Reserve the funds now so concurrent pending transfers can't spend the
+	// same balance twice.
+	var lockedOrigin model.Account
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lockedOrigin, "id = ?", transfer.OriginAccountID).Error; err != nil {
+		log.Errorw("Transfer failed: Origin account not found", "error", err)
+		return &ServiceError{Message: "Origin account not found", Code: http.StatusNotFound}
+	}
+
+	// It may have been frozen or closed since it was first read.
+	if serr := requireActiveAccounts(&lockedOrigin, destinationAccount, ctx); serr != nil {
+		return serr
+	}
 
-	if err := s.db.Create(&transfer).Error; err != nil {
+	if lockedOrigin.AvailableBalance() < transfer.Amount {
+		log.Errorw("Transfer failed: Insufficient funds", "account_id", lockedOrigin.ID, "amount", transfer.Amount)
+		return &ServiceError{Message: "Insufficient funds", Code: http.StatusBadRequest}
+	}
+
+	if err := tx.Model(&lockedOrigin).Update("held_balance", gorm.Expr("held_balance + ?", transfer.Amount)).Error; err != nil {
+		log.Errorw("Transfer failed: Unable to place hold", "account_id", lockedOrigin.ID, "error", err)
+		return &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+	}
+	transfer.HeldAmount = transfer.Amount
+
+	if err := tx.Create(transfer).Error; err != nil {
 		log.Errorw("Transfer failed: Unable to create transfer", "error", err)
-		return model.Transfer{}, &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+		return &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
 	}
 
-	log.Infow("Transfer created successfully", "transfer", transfer)
+	if err := recordInitialStatus(tx, transfer, actorFrom(ctx)); err != nil {
+		log.Errorw("Transfer failed: Unable to record status history", "error", err)
+		return &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+	}
 
-	return transfer, nil
+	if err := enqueueTransferEvent(tx, constant.EventTransferCreated, transfer); err != nil {
+		log.Errorw("Transfer failed: Unable to record event", "error", err)
+		return &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+	}
+
+	if transfer.QuoteID != nil {
+		// Consume the quote; the guard on transfer_id makes a concurrent
+		// second use of the same quote lose the race.
+		result := tx.Model(&model.FXQuote{}).
+			Where("id = ? AND transfer_id IS NULL", *transfer.QuoteID).
+			Update("transfer_id", transfer.ID)
+		if result.Error != nil {
+			log.Errorw("Transfer failed: Unable to consume quote", "quote_id", *transfer.QuoteID, "error", result.Error)
+			return &ServiceError{Message: "Unable to create transfer", Code: http.StatusInternalServerError}
+		}
+		if result.RowsAffected == 0 {
+			log.Warnw("Transfer failed: Quote already used", "quote_id", *transfer.QuoteID)
+			return &ServiceError{Message: "Quote has already been used", Code: http.StatusConflict}
+		}
+	}
+
+	return nil
 }
 
-func (s *transferService) UpdateTransferStatus(transferID string, status string, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+// requireActiveAccounts refuses to move money from or to a frozen or closed
+// account.
+func requireActiveAccounts(origin, destination *model.Account, ctx *gin.Context) *ServiceError {
 	log := logger.From(ctx)
 
-	var transfer model.Transfer
-	if err := s.db.First(&transfer, transferID).Error; err != nil {
-		log.Errorw("Transfer not found", "transfer_id", transferID, "error", err)
-		return nil, &ServiceError{Message: "Transfer not found", Code: http.StatusNotFound}
+	if origin.Status != constant.AccountStatusActive {
+		log.Warnw("Transfer failed: Origin account is not active", "account_id", origin.ID, "status", origin.Status)
+		return &ServiceError{Message: "Origin account is " + strings.ToLower(origin.Status), Code: http.StatusUnprocessableEntity}
+	}
+	if destination.Status != constant.AccountStatusActive {
+		log.Warnw("Transfer failed: Destination account is not active", "account_id", destination.ID, "status", destination.Status)
+		return &ServiceError{Message: "Destination account is " + strings.ToLower(destination.Status), Code: http.StatusUnprocessableEntity}
+	}
+	return nil
+}
+
+// applyQuote converts the transfer's amount into the destination currency at
+// the rate locked by the given quote.
+func applyQuote(tx *gorm.DB, transfer *model.Transfer, quoteID string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	if quoteID == "" {
+		log.Errorw("Transfer failed: Cross-currency transfer without quote", "currency", transfer.Currency, "destination_currency", transfer.DestinationCurrency)
+		return &ServiceError{Message: "A quote is required for cross-currency transfers", Code: http.StatusUnprocessableEntity}
+	}
+
+	var quote model.FXQuote
+	if err := tx.First(&quote, "id = ?", quoteID).Error; err != nil {
+		log.Errorw("Transfer failed: Quote not found", "quote_id", quoteID, "error", err)
+		return &ServiceError{Message: "Quote not found", Code: http.StatusNotFound}
+	}
+
+	if quote.SourceCurrency != transfer.Currency || quote.TargetCurrency != transfer.DestinationCurrency {
+		log.Errorw("Transfer failed: Quote does not match transfer currencies", "quote_id", quoteID,
+			"quote_source", quote.SourceCurrency, "quote_target", quote.TargetCurrency)
+		return &ServiceError{Message: "Quote does not match the account currencies", Code: http.StatusUnprocessableEntity}
+	}
+
+	if quote.TransferID != nil {
+		log.Warnw("Transfer failed: Quote already used", "quote_id", quoteID, "transfer_id", *quote.TransferID)
+		return &ServiceError{Message: "Quote has already been used", Code: http.StatusConflict}
+	}
+
+	if time.Now().After(quote.ExpiresAt) {
+		log.Warnw("Transfer failed: Quote expired", "quote_id", quoteID, "expires_at", quote.ExpiresAt)
+		return &ServiceError{Message: "Quote has expired", Code: http.StatusUnprocessableEntity}
+	}
+
+	rate, err := money.ParseRate(quote.Rate)
+	if err != nil {
+		log.Errorw("Transfer failed: Stored quote has an invalid rate", "quote_id", quoteID, "error", err)
+		return &ServiceError{Message: "Unable to apply quote", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	converted, err := rate.Convert(transfer.Amount, money.CurrencyFor(quote.SourceCurrency), money.CurrencyFor(quote.TargetCurrency))
+	if err != nil || converted <= 0 {
+		log.Errorw("Transfer failed: Converted amount is not valid", "quote_id", quoteID, "error", err)
+		return &ServiceError{Message: "Invalid transfer amount for this quote", Code: http.StatusBadRequest, Error: err}
+	}
+
+	transfer.DestinationAmount = converted
+	transfer.FXRate = quote.Rate
+	transfer.QuoteID = &quote.ID
+	return nil
+}
+
+func (s *transferService) UpdateTransferStatus(transferID string, status string, reason string, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+	log := logger.From(ctx)
+
+	transfer, serr := s.findTransfer(transferID, ctx)
+	if serr != nil {
+		return nil, serr
 	}
 
-	if status != constant.TransferStatusPending && status != constant.TransferStatusCompleted && status != constant.TransferStatusFailed {
+	// The provider can only report progress, success or failure.
+	if status != constant.TransferStatusProcessing && status != constant.TransferStatusCompleted && status != constant.TransferStatusFailed {
 		log.Errorw("Invalid transfer status", "status", status)
 		return nil, &ServiceError{Message: "Invalid transfer status", Code: http.StatusBadRequest}
 	}
 
-	if transfer.Status != constant.TransferStatusPending {
-		log.Errorw("Transfer can only be updated if it is pending", "transfer_id", transferID, "current_status", transfer.Status)
-		return nil, &ServiceError{Message: "Transfer can only be updated if it is pending", Code: http.StatusBadRequest}
+	if err := checkTransition(transfer.Status, status); err != nil {
+		log.Errorw("Invalid transfer status transition", "transfer_id", transferID, "current_status", transfer.Status, "status", status)
+		return nil, &ServiceError{Message: "Transfer cannot move from " + transfer.Status + " to " + status, Code: http.StatusConflict, Error: err}
+	}
+
+	log.Infow("Updating transfer status", "transfer_id", transfer.ID, "status", status)
+
+	switch status {
+	case constant.TransferStatusFailed:
+		return s.failTransfer(&transfer, reason, ctx)
+	case constant.TransferStatusProcessing:
+		return s.markProcessing(&transfer, reason, ctx)
+	}
+	return s.completeTransfer(&transfer, reason, ctx)
+}
+
+// GetTransfer returns a transfer to the owner of either of its accounts, or
+// to staff with transfers:read:any.
+func (s *transferService) GetTransfer(transferID string, ctx *gin.Context) (model.Transfer, *ServiceError) {
+	transfer, serr := s.findTransfer(transferID, ctx)
+	if serr != nil {
+		return model.Transfer{}, serr
+	}
+
+	if serr := authorizeTransferRead(&transfer, ctx); serr != nil {
+		return model.Transfer{}, serr
 	}
 
-	if status == constant.TransferStatusFailed {
-		transfer.Status = constant.TransferStatusFailed
-		s.db.Save(&transfer)
-		log.Infow("Transfer marked as failed", "transfer_id", transfer.ID)
-		return &transfer, nil
+	return transfer, nil
+}
+
+func (s *transferService) GetTransferHistory(transferID string, ctx *gin.Context) ([]model.TransferStatusHistory, *ServiceError) {
+	log := logger.From(ctx)
+
+	transfer, serr := s.findTransfer(transferID, ctx)
+	if serr != nil {
+		return nil, serr
+	}
+
+	if serr := authorizeTransferRead(&transfer, ctx); serr != nil {
+		return nil, serr
 	}
 
+	history := []model.TransferStatusHistory{}
+	if err := s.db.Where("transfer_id = ?", transfer.ID).Order("created_at, id").Find(&history).Error; err != nil {
+		log.Errorw("Failed to load transfer history", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Failed to load transfer history", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	return history, nil
+}
+
+// findTransfer loads a transfer by its path ID. IDs that aren't numeric
+// can't exist, so they are reported as not found too.
+func (s *transferService) findTransfer(transferID string, ctx *gin.Context) (model.Transfer, *ServiceError) {
+	log := logger.From(ctx)
+
+	id, err := strconv.ParseUint(transferID, 10, 64)
+	if err != nil {
+		log.Warnw("Transfer not found: Invalid ID", "transfer_id", transferID)
+		return model.Transfer{}, &ServiceError{Message: "Transfer not found", Code: http.StatusNotFound}
+	}
 
+	var transfer model.Transfer
+	if err := s.db.First(&transfer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warnw("Transfer not found", "transfer_id", transferID)
+			return model.Transfer{}, &ServiceError{Message: "Transfer not found", Code: http.StatusNotFound}
+		}
+		log.Errorw("Failed to load transfer", "transfer_id", transferID, "error", err)
+		return model.Transfer{}, &ServiceError{Message: "Failed to load transfer", Code: http.StatusInternalServerError, Error: err}
+	}
+	return transfer, nil
+}
 
-	log.Infow("Transfer status updated successfully", "transfer_id", transfer.ID, "status", transfer.Status)
+func authorizeTransferRead(transfer *model.Transfer, ctx *gin.Context) *ServiceError {
+	principal, _ := auth.PrincipalFrom(ctx)
+	if principal.HasScope(constant.ScopeTransfersReadAny) ||
+		auth.OwnsAccount(ctx, fmt.Sprint(transfer.OriginAccountID)) ||
+		auth.OwnsAccount(ctx, fmt.Sprint(transfer.DestinationAccountID)) {
+		return nil
+	}
 
-	return s.completeTransfer(&transfer, ctx)
+	logger.From(ctx).Warnw("Forbidden transfer read", "transfer_id", transfer.ID, "caller", principal.Subject)
+	return &ServiceError{Message: "Forbidden", Code: http.StatusForbidden}
 }
 
 func (s *transferService) CronExpireTransfers() (*ServiceError) {
 
-	timeLimit := time.Now().Add(-5 * time.Minute)
-	result := s.db.Model(&model.Transfer{}).
-		Where("status = ? AND updated_at < ?", constant.TransferStatusPending, timeLimit).
-		Update("status", constant.TransferStatusFailed)
-	
-	if result.Error != nil {
-		return &ServiceError{Message: "Failed to expire transfers", Code: http.StatusInternalServerError, Error: result.Error}
+	now := time.Now()
+	// Transfers created before expires_at existed fall back to the default
+	// expiry counted from their last update.
+	var expired []model.Transfer
+	err := s.db.Where("status = ? AND (expires_at < ? OR (expires_at IS NULL AND updated_at < ?))",
+		constant.TransferStatusPending, now, now.Add(-s.expiry)).
+		Find(&expired).Error
+	if err != nil {
+		return &ServiceError{Message: "Failed to expire transfers", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	count, failed := 0, 0
+	for _, candidate := range expired {
+		expiredNow := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var transfer model.Transfer
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, candidate.ID).Error; err != nil {
+				return err
+			}
+			// The webhook may have settled it since we listed it.
+			if transfer.Status != constant.TransferStatusPending {
+				return nil
+			}
+			if err := releaseHold(tx, &transfer); err != nil {
+				return err
+			}
+			if err := transitionTransfer(tx, &transfer, constant.TransferStatusExpired, constant.TransferActorScheduler, expiryReason(&transfer)); err != nil {
+				return err
+			}
+			if err := enqueueTransferEvent(tx, constant.EventTransferExpired, &transfer); err != nil {
+				return err
+			}
+			expiredNow = true
+			return nil
+		})
+		// One transfer that can't be expired mustn't keep the rest holding
+		// their funds; it is retried on the next run.
+		if err != nil {
+			log.Println("[Cron] Failed to expire transfer", candidate.ID, ":", err)
+			failed++
+			continue
+		}
+		if expiredNow {
+			count++
+		}
+	}
+
+	if count > 0 || failed > 0 {
+		log.Println("[Cron] Expired transfers", "count", count, "failed", failed)
+	}
+	if failed > 0 {
+		return &ServiceError{Message: fmt.Sprintf("Failed to expire %d transfers", failed), Code: http.StatusInternalServerError}
+	}
+
+	return nil
+}
+
+func expiryReason(transfer *model.Transfer) string {
+	if transfer.ExpiresAt == nil {
+		return "not completed before the default expiry"
 	}
+	return "not completed before " + transfer.ExpiresAt.UTC().Format(time.RFC3339)
+}
 
-	if result.RowsAffected > 0 {
-		log.Println("[Cron] Expired transfers", "count", result.RowsAffected)
+// lockForTransition re-reads the transfer under a row lock and checks that
+// it can still move to the given status, since it may have changed after the
+// caller first loaded it.
+func lockForTransition(tx *gorm.DB, transfer *model.Transfer, to string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(transfer, transfer.ID).Error; err != nil {
+		log.Errorw("Transfer not found", "transfer_id", transfer.ID, "error", err)
+		return &ServiceError{Message: "Transfer not found", Code: http.StatusNotFound}
+	}
+
+	if err := checkTransition(transfer.Status, to); err != nil {
+		log.Errorw("Invalid transfer status transition", "transfer_id", transfer.ID, "current_status", transfer.Status, "status", to)
+		return &ServiceError{Message: "Transfer cannot move from " + transfer.Status + " to " + to, Code: http.StatusConflict, Error: err}
 	}
 
 	return nil
 }
 
-func (s *transferService) completeTransfer(transfer *model.Transfer, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+// markProcessing records that the provider has picked the transfer up. The
+// hold stays in place until it completes or fails.
+func (s *transferService) markProcessing(transfer *model.Transfer, reason string, ctx *gin.Context) (*model.Transfer, *ServiceError) {
 	log := logger.From(ctx)
 
 	tx := s.db.Begin()
 
-	var originAccount, destinationAccount model.Account
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&originAccount, "id = ?", transfer.OriginAccountID).Error; err != nil {
+	if serr := lockForTransition(tx, transfer, constant.TransferStatusProcessing, ctx); serr != nil {
 		tx.Rollback()
-		log.Errorw("Transfer failed: Origin account not found", "error", err)
-		return nil, &ServiceError{Message: "Origin account not found", Code: http.StatusNotFound}
+		return nil, serr
+	}
+
+	if err := transitionTransfer(tx, transfer, constant.TransferStatusProcessing, constant.TransferActorWebhook, reason); err != nil {
+		tx.Rollback()
+		log.Errorw("Transfer failed: Unable to update transfer", "error", err)
+		return nil, &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
+	}
+
+	tx.Commit()
+	log.Infow("Transfer marked as processing", "transfer_id", transfer.ID)
+	return transfer, nil
+}
+
+// failTransfer marks a transfer as failed and releases its hold.
+func (s *transferService) failTransfer(transfer *model.Transfer, reason string, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+	log := logger.From(ctx)
+
+	tx := s.db.Begin()
+
+	if serr := lockForTransition(tx, transfer, constant.TransferStatusFailed, ctx); serr != nil {
+		tx.Rollback()
+		return nil, serr
+	}
+
+	if serr := markFailed(tx, transfer, reason, ctx); serr != nil {
+		tx.Rollback()
+		return nil, serr
+	}
+
+	tx.Commit()
+	log.Infow("Transfer marked as failed", "transfer_id", transfer.ID)
+	return transfer, nil
+}
+
+// markFailed releases the transfer's hold and moves it to FAILED within tx.
+// The transfer must already be locked.
+func markFailed(tx *gorm.DB, transfer *model.Transfer, reason string, ctx *gin.Context) *ServiceError {
+	log := logger.From(ctx)
+
+	if err := releaseHold(tx, transfer); err != nil {
+		log.Errorw("Transfer failed: Unable to release hold", "transfer_id", transfer.ID, "error", err)
+		return &ServiceError{Message: "Unable to release hold", Code: http.StatusInternalServerError}
+	}
+
+	if err := transitionTransfer(tx, transfer, constant.TransferStatusFailed, constant.TransferActorWebhook, reason); err != nil {
+		log.Errorw("Transfer failed: Unable to update transfer", "error", err)
+		return &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
+	}
+
+	if err := enqueueTransferEvent(tx, constant.EventTransferFailed, transfer); err != nil {
+		log.Errorw("Transfer failed: Unable to record event", "transfer_id", transfer.ID, "error", err)
+		return &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
+	}
+
+	return nil
+}
+
+// refuseCompletion fails a transfer that can't be completed, e.g. because an
+// account was frozen after it was created, so that its hold isn't left
+// reserved for a transfer that will never settle. It commits tx and returns
+// serr, why the completion was refused.
+func refuseCompletion(tx *gorm.DB, transfer *model.Transfer, serr *ServiceError, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+	if ferr := markFailed(tx, transfer, serr.Message, ctx); ferr != nil {
+		tx.Rollback()
+		return nil, ferr
+	}
+
+	tx.Commit()
+	logger.From(ctx).Infow("Transfer marked as failed: completion refused", "transfer_id", transfer.ID, "reason", serr.Message)
+	return nil, serr
+}
+
+// enqueueTransferEvent records a transfer event in the outbox as part of tx,
+// so it is published only if the change it describes commits.
+func enqueueTransferEvent(tx *gorm.DB, eventType string, transfer *model.Transfer) error {
+	return outbox.Enqueue(tx, eventType, transfer.ID, transfer.ToResponse())
+}
+
+// lockAccounts locks both accounts of a transfer in ascending ID order, so
+// that transactions moving money between the same two accounts, in either
+// direction, queue up instead of deadlocking. missing names the side that
+// wasn't found.
+func lockAccounts(tx *gorm.DB, originID, destinationID uint) (origin, destination model.Account, missing string, err error) {
+	first, second := &origin, &destination
+	firstID, secondID := originID, destinationID
+	firstName, secondName := "Origin", "Destination"
+	if destinationID < originID {
+		first, second = second, first
+		firstID, secondID = secondID, firstID
+		firstName, secondName = secondName, firstName
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(first, "id = ?", firstID).Error; err != nil {
+		return model.Account{}, model.Account{}, firstName, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(second, "id = ?", secondID).Error; err != nil {
+		return model.Account{}, model.Account{}, secondName, err
+	}
+	return origin, destination, "", nil
+}
+
+// releaseHold gives the transfer's reserved funds back to the origin account.
+func releaseHold(tx *gorm.DB, transfer *model.Transfer) error {
+	if transfer.HeldAmount == 0 {
+		return nil
+	}
+	err := tx.Model(&model.Account{}).Where("id = ?", transfer.OriginAccountID).
+		Update("held_balance", gorm.Expr("held_balance - ?", transfer.HeldAmount)).Error
+	if err != nil {
+		return err
+	}
+	transfer.HeldAmount = 0
+	return nil
+}
+
+func (s *transferService) completeTransfer(transfer *model.Transfer, reason string, ctx *gin.Context) (*model.Transfer, *ServiceError) {
+	log := logger.From(ctx)
+
+	tx := s.db.Begin()
+
+	if serr := lockForTransition(tx, transfer, constant.TransferStatusCompleted, ctx); serr != nil {
+		tx.Rollback()
+		return nil, serr
 	}
 
-	if originAccount.Balance < transfer.Amount {
+	originAccount, destinationAccount, missing, err := lockAccounts(tx, transfer.OriginAccountID, transfer.DestinationAccountID)
+	if err != nil {
 		tx.Rollback()
+		log.Errorw("Transfer failed: "+missing+" account not found", "error", err)
+		return nil, &ServiceError{Message: missing + " account not found", Code: http.StatusNotFound}
+	}
+
+	// Convert the hold into the debit: release it, then the funds it was
+	// reserving must still be available.
+	originHeld, err := originAccount.HeldBalance.Sub(transfer.HeldAmount)
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Transfer failed: Held balance out of range", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Held balance out of range", Code: http.StatusUnprocessableEntity, Error: err}
+	}
+	originAccount.HeldBalance = originHeld
+
+	// None of these will change on a retry, so the transfer fails instead.
+	if originAccount.AvailableBalance() < transfer.Amount {
 		log.Errorw("Transfer failed: Insufficient funds", "transfer_id", transfer.ID)
-		return nil, &ServiceError{Message: "Insufficient funds", Code: http.StatusBadRequest}
+		return refuseCompletion(tx, transfer, &ServiceError{Message: "Insufficient funds", Code: http.StatusBadRequest}, ctx)
+	}
+
+	if serr := requireActiveAccounts(&originAccount, &destinationAccount, ctx); serr != nil {
+		return refuseCompletion(tx, transfer, serr, ctx)
 	}
 
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&destinationAccount, "id = ?", transfer.DestinationAccountID).Error; err != nil {
+	if originAccount.Currency != transfer.Currency || destinationAccount.Currency != transfer.DestinationCurrency {
+		log.Errorw("Transfer failed: Currency mismatch", "transfer_id", transfer.ID, "currency", transfer.Currency)
+		return refuseCompletion(tx, transfer, &ServiceError{Message: "Currency mismatch between accounts and transfer", Code: http.StatusUnprocessableEntity}, ctx)
+	}
+	transfer.HeldAmount = 0
+
+	originBalance, err := originAccount.Balance.Sub(transfer.Amount)
+	if err != nil {
 		tx.Rollback()
-		log.Errorw("Transfer failed: Destination account not found", "error", err)
-		return nil, &ServiceError{Message: "Destination account not found", Code: http.StatusNotFound}
+		log.Errorw("Transfer failed: Origin balance out of range", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Origin balance out of range", Code: http.StatusUnprocessableEntity, Error: err}
 	}
 
-	originAccount.Balance -= transfer.Amount
-	destinationAccount.Balance += transfer.Amount
-	transfer.Status = constant.TransferStatusCompleted
+	destinationBalance, err := destinationAccount.Balance.Add(transfer.DestinationAmount)
+	if err != nil {
+		tx.Rollback()
+		log.Errorw("Transfer failed: Destination balance out of range", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Destination balance out of range", Code: http.StatusUnprocessableEntity, Error: err}
+	}
+
+	originAccount.Balance = originBalance
+	destinationAccount.Balance = destinationBalance
 
 	if err := tx.Save(&originAccount).Error; err != nil {
 		tx.Rollback()
@@ -165,12 +675,24 @@ func (s *transferService) completeTransfer(transfer *model.Transfer, ctx *gin.Co
 		return nil, &ServiceError{Message: "Unable to update destination account", Code: http.StatusInternalServerError}
 	}
 
-	if err := tx.Save(&transfer).Error; err != nil {
+	if err := transitionTransfer(tx, transfer, constant.TransferStatusCompleted, constant.TransferActorWebhook, reason); err != nil {
 		tx.Rollback()
 		log.Errorw("Transfer failed: Unable to update transfer", "error", err)
 		return nil, &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
 	}
 
+	if err := ledger.PostTransfer(tx, transfer); err != nil {
+		tx.Rollback()
+		log.Errorw("Transfer failed: Unable to post ledger entries", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Unable to post ledger entries", Code: http.StatusInternalServerError, Error: err}
+	}
+
+	if err := enqueueTransferEvent(tx, constant.EventTransferCompleted, transfer); err != nil {
+		tx.Rollback()
+		log.Errorw("Transfer failed: Unable to record event", "transfer_id", transfer.ID, "error", err)
+		return nil, &ServiceError{Message: "Unable to update transfer", Code: http.StatusInternalServerError}
+	}
+
 	tx.Commit()
 	log.Infow("Transfer completed successfully", "transfer_id", transfer.ID)
 	return transfer, nil
@@ -1,11 +1,21 @@
 package service_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"payment-service/internal/constant"
+	"payment-service/internal/ledger"
+	"payment-service/internal/middleware/auth"
 	"payment-service/internal/middleware/logger"
 	"payment-service/internal/model"
+	"payment-service/internal/money"
 	"payment-service/internal/service"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -19,31 +29,44 @@ func setupTransferTestDB() *gorm.DB {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 
-	err = db.AutoMigrate(&model.Transfer{}, &model.Account{})
+	err = db.AutoMigrate(&model.Transfer{}, &model.Account{}, &model.FXQuote{}, &model.LedgerEntry{}, &model.TransferStatusHistory{}, &model.BalanceSnapshot{}, &model.OutboxEvent{})
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
 
-	account1 := model.Account{Name: "Test Account 1", Balance: 100.0}
-	account2 := model.Account{Name: "Test Account 2", Balance: 200.0}
+	account1 := model.Account{Name: "Test Account 1", Balance: money.Amount(10000)}
+	account2 := model.Account{Name: "Test Account 2", Balance: money.Amount(20000)}
+	account3 := model.Account{Name: "Test Account 3", Currency: "EUR", Balance: money.Amount(30000)}
 	db.Create(&account1)
 	db.Create(&account2)
+	db.Create(&account3)
+	if err := ledger.BackfillOpeningBalances(db); err != nil {
+		log.Fatalf("failed to post opening balances: %v", err)
+	}
 
 	return db
 }
 
+// authedContext returns a context authenticated as the given account.
+func authedContext(accountID uint) *gin.Context {
+	ctx := &gin.Context{}
+	auth.SetPrincipal(ctx, auth.Principal{AccountID: fmt.Sprint(accountID)})
+	return ctx
+}
+
 func TestCreateTransfer_Success(t *testing.T) {
 	db := setupTransferTestDB()
-	transferService := service.NewTransferService(db)
+	transferService := service.NewTransferService(db, 5*time.Minute)
 
-	ctx := &gin.Context{}
+	ctx := authedContext(1)
 	logger.Init("test")
 
-	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: 50.0}
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "50.00"}
 	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
 
 	assert.Nil(t, err)
-	assert.Equal(t, float64(50.0), newTransfer.Amount)
+	assert.Equal(t, money.Amount(5000), newTransfer.Amount)
+	assert.Equal(t, "USD", newTransfer.Currency)
 	assert.Equal(t, "PENDING", newTransfer.Status)
 	assert.Equal(t, uint(1), newTransfer.OriginAccountID)
 	assert.Equal(t, uint(2), newTransfer.DestinationAccountID)
@@ -51,12 +74,12 @@ func TestCreateTransfer_Success(t *testing.T) {
 
 func TestCreateTransfer_AmountZero(t *testing.T) {
 	db := setupTransferTestDB()
-	transferService := service.NewTransferService(db)
+	transferService := service.NewTransferService(db, 5*time.Minute)
 
-	ctx := &gin.Context{}
+	ctx := authedContext(1)
 	logger.Init("test")
 
-	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: 0.0}
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "0"}
 	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
 
 	assert.NotNil(t, err)
@@ -66,12 +89,12 @@ func TestCreateTransfer_AmountZero(t *testing.T) {
 
 func TestCreateTransfer_SameAccount(t *testing.T) {
 	db := setupTransferTestDB()
-	transferService := service.NewTransferService(db)
+	transferService := service.NewTransferService(db, 5*time.Minute)
 
-	ctx := &gin.Context{}
+	ctx := authedContext(1)
 	logger.Init("test")
 
-	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 1, Amount: 50.0}
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 1, Amount: "50.00"}
 	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
 
 	assert.NotNil(t, err)
@@ -81,12 +104,12 @@ func TestCreateTransfer_SameAccount(t *testing.T) {
 
 func TestCreateTransfer_OriginAccountNotFound(t *testing.T) {
 	db := setupTransferTestDB()
-	transferService := service.NewTransferService(db)
+	transferService := service.NewTransferService(db, 5*time.Minute)
 
-	ctx := &gin.Context{}
+	ctx := authedContext(999)
 	logger.Init("test")
 
-	testTransfer := model.TransferRequest{OriginAccountID: 999, DestinationAccountID: 2, Amount: 50.0}
+	testTransfer := model.TransferRequest{OriginAccountID: 999, DestinationAccountID: 2, Amount: "50.00"}
 	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
 
 	assert.NotNil(t, err)
@@ -96,15 +119,494 @@ func TestCreateTransfer_OriginAccountNotFound(t *testing.T) {
 
 func TestCreateTransfer_DestinationAccountNotFound(t *testing.T) {
 	db := setupTransferTestDB()
-	transferService := service.NewTransferService(db)
+	transferService := service.NewTransferService(db, 5*time.Minute)
 
-	ctx := &gin.Context{}
+	ctx := authedContext(1)
 	logger.Init("test")
 
-	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 999, Amount: 50.0}
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 999, Amount: "50.00"}
 	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "Destination account not found", err.Message)
 	assert.Equal(t, model.Transfer{}, newTransfer)
-}
\ No newline at end of file
+}
+
+func TestCreateTransfer_TooManyDecimals(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.001"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+	assert.Equal(t, model.Transfer{}, newTransfer)
+}
+
+func TestUpdateTransferStatus_CompletedIsExact(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	for i := 0; i < 10; i++ {
+		testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "0.10"}
+		newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+		assert.Nil(t, err)
+
+		_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "COMPLETED", "", ctx)
+		assert.Nil(t, err)
+	}
+
+	var origin, destination model.Account
+	db.First(&origin, 1)
+	db.First(&destination, 2)
+	assert.Equal(t, "99.00", origin.Balance.Format(money.DefaultCurrency()))
+	assert.Equal(t, "201.00", destination.Balance.Format(money.DefaultCurrency()))
+
+	originLedger, _ := ledger.AccountBalance(db, origin.ID)
+	destinationLedger, _ := ledger.AccountBalance(db, destination.ID)
+	assert.Equal(t, origin.Balance, originLedger)
+	assert.Equal(t, destination.Balance, destinationLedger)
+
+	var entries []model.LedgerEntry
+	db.Where("transfer_id IS NOT NULL").Find(&entries)
+	assert.Len(t, entries, 20)
+}
+
+func TestCreateTransfer_CurrencyMismatch(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 3, Amount: "50.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+	assert.Equal(t, model.Transfer{}, newTransfer)
+}
+
+func TestCreateTransfer_RequestCurrencyMismatch(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "50.00", Currency: "EUR"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+	assert.Equal(t, model.Transfer{}, newTransfer)
+}
+
+func TestCreateTransfer_HoldsFunds(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "100.00"}
+	_, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.Nil(t, err)
+
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Insufficient funds", err.Message)
+	assert.Equal(t, model.Transfer{}, newTransfer)
+
+	var origin model.Account
+	db.First(&origin, 1)
+	assert.Equal(t, money.Amount(10000), origin.Balance)
+	assert.Equal(t, money.Amount(0), origin.AvailableBalance())
+}
+
+func TestUpdateTransferStatus_FailedReleasesHold(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "40.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.Nil(t, err)
+
+	updated, err := transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "FAILED", "", ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "FAILED", updated.Status)
+
+	var origin model.Account
+	db.First(&origin, 1)
+	assert.Equal(t, money.Amount(0), origin.HeldBalance)
+	assert.Equal(t, money.Amount(10000), origin.AvailableBalance())
+}
+
+func TestCronExpireTransfers_ReleasesHold(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "40.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.Nil(t, err)
+
+	db.Model(&model.Transfer{}).Where("id = ?", newTransfer.ID).UpdateColumn("expires_at", time.Now().Add(-time.Second))
+
+	assert.Nil(t, transferService.CronExpireTransfers())
+
+	var expired model.Transfer
+	var origin model.Account
+	db.First(&expired, newTransfer.ID)
+	db.First(&origin, 1)
+	assert.Equal(t, "EXPIRED", expired.Status)
+	assert.Contains(t, expired.StatusReason, "not completed before")
+	assert.Equal(t, money.Amount(0), origin.HeldBalance)
+
+	history, err := transferService.GetTransferHistory(fmt.Sprint(newTransfer.ID), ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "EXPIRED", history[len(history)-1].ToStatus)
+	assert.Equal(t, "scheduler", history[len(history)-1].Actor)
+}
+
+func TestCronExpireTransfers_SkipsPastAFailure(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	first, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.Nil(t, err)
+	second, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "20.00"}, ctx)
+	assert.Nil(t, err)
+	db.Model(&model.Transfer{}).Where("id IN ?", []uint{first.ID, second.ID}).UpdateColumn("expires_at", time.Now().Add(-time.Second))
+
+	// The first transfer can't be written, as with a lock timeout.
+	db.Exec(fmt.Sprintf("CREATE TRIGGER stuck_transfer BEFORE UPDATE ON transfers WHEN OLD.id = %d BEGIN SELECT RAISE(ABORT, 'locked'); END", first.ID))
+
+	assert.NotNil(t, transferService.CronExpireTransfers())
+
+	var stuck, expired model.Transfer
+	var origin model.Account
+	db.First(&stuck, first.ID)
+	db.First(&expired, second.ID)
+	db.First(&origin, 1)
+	assert.Equal(t, constant.TransferStatusPending, stuck.Status)
+	assert.Equal(t, constant.TransferStatusExpired, expired.Status)
+	assert.Equal(t, money.Amount(1000), origin.HeldBalance)
+
+	var events int64
+	db.Model(&model.OutboxEvent{}).Where("type = ?", constant.EventTransferExpired).Count(&events)
+	assert.Equal(t, int64(1), events)
+}
+
+func TestCronExpireTransfers_RespectsPerTransferExpiry(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	shortLived := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", ExpiresIn: 1}
+	short, err := transferService.CreateTransfer(&shortLived, ctx)
+	assert.Nil(t, err)
+
+	defaultLived := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}
+	long, err := transferService.CreateTransfer(&defaultLived, ctx)
+	assert.Nil(t, err)
+	assert.True(t, long.ExpiresAt.After(time.Now().Add(4*time.Minute)))
+
+	db.Model(&model.Transfer{}).Where("id = ?", short.ID).UpdateColumn("expires_at", time.Now().Add(-time.Second))
+	assert.Nil(t, transferService.CronExpireTransfers())
+
+	var shortAfter, longAfter model.Transfer
+	db.First(&shortAfter, short.ID)
+	db.First(&longAfter, long.ID)
+	assert.Equal(t, "EXPIRED", shortAfter.Status)
+	assert.Equal(t, "PENDING", longAfter.Status)
+}
+
+func TestCreateTransfer_InvalidExpiry(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00", ExpiresIn: -5}
+	_, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+}
+
+func TestUpdateTransferStatus_RejectsIllegalTransition(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.Nil(t, err)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "PENDING", "", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "COMPLETED", "", ctx)
+	assert.Nil(t, err)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "FAILED", "", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, err.Code)
+}
+
+func TestGetTransferHistory_RecordsEveryTransition(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+	assert.Nil(t, err)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "PROCESSING", "accepted by provider", ctx)
+	assert.Nil(t, err)
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(newTransfer.ID), "FAILED", "rejected by bank", ctx)
+	assert.Nil(t, err)
+
+	history, err := transferService.GetTransferHistory(fmt.Sprint(newTransfer.ID), ctx)
+	assert.Nil(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, "", history[0].FromStatus)
+	assert.Equal(t, "PENDING", history[0].ToStatus)
+	assert.Equal(t, "PENDING", history[1].FromStatus)
+	assert.Equal(t, "PROCESSING", history[1].ToStatus)
+	assert.Equal(t, "FAILED", history[2].ToStatus)
+	assert.Equal(t, "webhook", history[2].Actor)
+	assert.Equal(t, "rejected by bank", history[2].Reason)
+
+	var origin model.Account
+	db.First(&origin, 1)
+	assert.Equal(t, money.Amount(0), origin.HeldBalance)
+}
+
+func TestGetTransferHistory_NotFound(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	_, err := transferService.GetTransferHistory("999", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Code)
+}
+
+func TestCreateTransfer_ForbiddenForOtherAccount(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(2)
+	logger.Init("test")
+
+	testTransfer := model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}
+	newTransfer, err := transferService.CreateTransfer(&testTransfer, ctx)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Code)
+	assert.Equal(t, model.Transfer{}, newTransfer)
+
+	_, err = transferService.CreateTransfer(&testTransfer, &gin.Context{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Code)
+}
+
+func TestCreateTransfer_RefusesFrozenOrClosedAccounts(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	db.Model(&model.Account{}).Where("id = ?", 2).Update("status", constant.AccountStatusFrozen)
+
+	_, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+	assert.Equal(t, "Destination account is frozen", err.Message)
+
+	db.Model(&model.Account{}).Where("id = ?", 1).Update("status", constant.AccountStatusClosed)
+
+	_, err = transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Origin account is closed", err.Message)
+}
+
+func TestUpdateTransferStatus_CompletionRefusedForFrozenAccount(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	logger.Init("test")
+
+	transfer, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, authedContext(1))
+	assert.Nil(t, err)
+
+	db.Model(&model.Account{}).Where("id = ?", 2).Update("status", constant.AccountStatusFrozen)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(transfer.ID), constant.TransferStatusCompleted, "", &gin.Context{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+
+	var origin, destination model.Account
+	db.First(&origin, 1)
+	db.First(&destination, 2)
+	assert.Equal(t, money.Amount(10000), origin.Balance)
+	assert.Equal(t, money.Amount(0), origin.HeldBalance)
+	assert.Equal(t, money.Amount(20000), destination.Balance)
+
+	// The transfer can never complete, so it fails rather than keeping the
+	// funds held.
+	var stored model.Transfer
+	db.First(&stored, transfer.ID)
+	assert.Equal(t, constant.TransferStatusFailed, stored.Status)
+	assert.Equal(t, "Destination account is frozen", stored.StatusReason)
+	assert.Equal(t, money.Amount(0), stored.HeldAmount)
+}
+
+func TestUpdateTransferStatus_LongReasonIsCut(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	logger.Init("test")
+
+	transfer, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, authedContext(1))
+	assert.Nil(t, err)
+
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(transfer.ID), constant.TransferStatusFailed, strings.Repeat("é", 200), &gin.Context{})
+	assert.Nil(t, err)
+
+	var stored model.Transfer
+	db.First(&stored, transfer.ID)
+	assert.Equal(t, strings.Repeat("é", 127), stored.StatusReason)
+
+	var history model.TransferStatusHistory
+	db.Where("transfer_id = ?", transfer.ID).Order("id DESC").First(&history)
+	assert.True(t, utf8.ValidString(history.Reason))
+	assert.Equal(t, stored.StatusReason, history.Reason)
+}
+
+func TestGetTransfer_Detail(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	logger.Init("test")
+
+	created, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, authedContext(1))
+	assert.Nil(t, err)
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(created.ID), constant.TransferStatusFailed, "rejected by bank", &gin.Context{})
+	assert.Nil(t, err)
+
+	// Both sides of the transfer can read it.
+	for _, accountID := range []uint{1, 2} {
+		transfer, err := transferService.GetTransfer(fmt.Sprint(created.ID), authedContext(accountID))
+		assert.Nil(t, err)
+		response := transfer.ToResponse()
+		assert.Equal(t, constant.TransferStatusFailed, response.Status)
+		assert.Equal(t, "rejected by bank", response.FailureReason)
+		assert.False(t, response.CreatedAt.IsZero())
+		assert.NotNil(t, response.ExpiresAt)
+	}
+
+	_, err = transferService.GetTransfer(fmt.Sprint(created.ID), authedContext(3))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	_, err = transferService.GetTransferHistory(fmt.Sprint(created.ID), authedContext(3))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Code)
+
+	support := &gin.Context{}
+	auth.SetPrincipal(support, auth.Principal{Subject: "staff:sam", Scopes: constant.RoleScopes[constant.RoleSupport]})
+	_, err = transferService.GetTransfer(fmt.Sprint(created.ID), support)
+	assert.Nil(t, err)
+}
+
+func TestGetTransfer_NotFound(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	logger.Init("test")
+
+	for _, id := range []string{"999", "abc", "1 OR 1=1", "-1"} {
+		_, err := transferService.GetTransfer(id, authedContext(1))
+		assert.NotNil(t, err, id)
+		assert.Equal(t, http.StatusNotFound, err.Code)
+	}
+}
+
+// outboxEventTypes lists the event types recorded for a transfer, in order.
+func outboxEventTypes(db *gorm.DB, transferID uint) []string {
+	var types []string
+	db.Model(&model.OutboxEvent{}).Where("aggregate_id = ?", transferID).Order("id").Pluck("type", &types)
+	return types
+}
+
+func TestTransferEvents_WrittenWithStateChanges(t *testing.T) {
+	db := setupTransferTestDB()
+	transferService := service.NewTransferService(db, 5*time.Minute)
+
+	ctx := authedContext(1)
+	logger.Init("test")
+
+	completed, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.Nil(t, err)
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(completed.ID), "COMPLETED", "", ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{constant.EventTransferCreated, constant.EventTransferCompleted}, outboxEventTypes(db, completed.ID))
+
+	failed, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.Nil(t, err)
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(failed.ID), "FAILED", "", ctx)
+	assert.Nil(t, err)
+	// A refused transition changes nothing, so it records nothing.
+	_, err = transferService.UpdateTransferStatus(fmt.Sprint(failed.ID), "COMPLETED", "", ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{constant.EventTransferCreated, constant.EventTransferFailed}, outboxEventTypes(db, failed.ID))
+
+	expired, err := transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, ctx)
+	assert.Nil(t, err)
+	db.Model(&model.Transfer{}).Where("id = ?", expired.ID).UpdateColumn("expires_at", time.Now().Add(-time.Second))
+	assert.Nil(t, transferService.CronExpireTransfers())
+	assert.Equal(t, []string{constant.EventTransferCreated, constant.EventTransferExpired}, outboxEventTypes(db, expired.ID))
+
+	// Rejected transfers never reach the outbox.
+	_, err = transferService.CreateTransfer(&model.TransferRequest{OriginAccountID: 1, DestinationAccountID: 2, Amount: "1000.00"}, ctx)
+	assert.NotNil(t, err)
+	var count int64
+	db.Model(&model.OutboxEvent{}).Count(&count)
+	assert.Equal(t, int64(6), count)
+
+	var event model.OutboxEvent
+	db.Where("aggregate_id = ? AND type = ?", completed.ID, constant.EventTransferCompleted).First(&event)
+	var payload model.TransferResponse
+	assert.NoError(t, json.Unmarshal([]byte(event.Payload), &payload))
+	assert.Equal(t, "COMPLETED", payload.Status)
+	assert.Equal(t, "10.00", payload.Amount)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"payment-service/internal/constant"
+	"payment-service/internal/middleware/auth"
+	"payment-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTransition = errors.New("invalid transfer status transition")
+
+// statusReasonLength is the size of the status_reason and history reason
+// columns.
+const statusReasonLength = 255
+
+// transferTransitions is the transfer state machine: the statuses each status
+// may move to. Statuses without an entry are terminal.
+var transferTransitions = map[string][]string{
+	constant.TransferStatusPending: {
+		constant.TransferStatusProcessing,
+		constant.TransferStatusCompleted,
+		constant.TransferStatusFailed,
+		constant.TransferStatusExpired,
+		constant.TransferStatusCancelled,
+	},
+	constant.TransferStatusProcessing: {
+		constant.TransferStatusCompleted,
+		constant.TransferStatusFailed,
+	},
+	constant.TransferStatusCompleted: {
+		constant.TransferStatusReversed,
+	},
+}
+
+// isTransferStatus reports whether status appears anywhere in the state
+// machine.
+func isTransferStatus(status string) bool {
+	for from, targets := range transferTransitions {
+		if from == status {
+			return true
+		}
+		for _, to := range targets {
+			if to == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkTransition(from, to string) error {
+	for _, allowed := range transferTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+}
+
+// transitionTransfer is the only place a transfer's status changes. It
+// rejects moves the state machine doesn't allow, saves the transfer and
+// records the change in the status history, all within tx. Reasons longer
+// than the columns hold are cut short.
+func transitionTransfer(tx *gorm.DB, transfer *model.Transfer, to, actor, reason string) error {
+	if err := checkTransition(transfer.Status, to); err != nil {
+		return err
+	}
+	reason = clip(reason, statusReasonLength)
+
+	history := model.TransferStatusHistory{
+		TransferID: transfer.ID,
+		FromStatus: transfer.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+	}
+
+	transfer.Status = to
+	transfer.StatusReason = reason
+	if err := tx.Save(transfer).Error; err != nil {
+		return err
+	}
+	return tx.Create(&history).Error
+}
+
+// recordInitialStatus writes the first history row of a newly created transfer.
+func recordInitialStatus(tx *gorm.DB, transfer *model.Transfer, actor string) error {
+	return tx.Create(&model.TransferStatusHistory{
+		TransferID: transfer.ID,
+		ToStatus:   transfer.Status,
+		Actor:      actor,
+	}).Error
+}
+
+// actorFrom names who is acting in the request for the status history.
+func actorFrom(ctx *gin.Context) string {
+	if id := auth.AccountID(ctx); id != "" {
+		return "account:" + id
+	}
+	return constant.TransferActorSystem
+}
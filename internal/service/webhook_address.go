@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// errWebhookAddress is returned for an endpoint host that resolves to an
+// address merchant webhooks may not be sent to.
+var errWebhookAddress = errors.New("address not allowed for webhooks")
+
+// publicAddress reports whether webhooks may be sent to ip. Addresses on
+// this host or an internal network are off limits, so an endpoint can't be
+// used to reach services behind the firewall (e.g. cloud metadata).
+func publicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// checkWebhookHost resolves host and fails unless all its addresses are
+// public.
+func checkWebhookHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr.IP) {
+			return fmt.Errorf("%w: %s", errWebhookAddress, addr.IP)
+		}
+	}
+	return nil
+}
+
+// webhookTransport only connects to public addresses. The check runs on the
+// address being dialled, after DNS resolution, so a host that was public at
+// registration can't later be pointed at an internal one.
+func webhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+				return fmt.Errorf("%w: %s", errWebhookAddress, host)
+			}
+			return nil
+		},
+	}
+
+	// No proxy: the dialer has to see the merchant's own address.
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: webhookTimeout,
+	}
+}
@@ -0,0 +1,131 @@
+// statement rendering as CSV and PDF
+package statement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"payment-service/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const dateLayout = "2006-01-02"
+
+// WriteCSV writes one row per statement line, preceded by the opening
+// balance and followed by the closing balance.
+func WriteCSV(w io.Writer, s model.Statement) error {
+	out := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"date", "reference", "transfer_id", "description", "counterparty_account_id", "counterparty_name", "amount", "balance", "currency"},
+		{s.From.Format(dateLayout), "", "", "Opening balance", "", "", "", s.OpeningBalance, s.Currency},
+	}
+	for _, line := range s.Lines {
+		rows = append(rows, []string{
+			line.Date.Format(time.RFC3339),
+			line.Reference,
+			optionalID(line.TransferID),
+			line.Description,
+			optionalID(line.CounterpartyAccountID),
+			line.CounterpartyName,
+			line.Amount,
+			line.Balance,
+			s.Currency,
+		})
+	}
+	rows = append(rows, []string{lastDay(s), "", "", "Closing balance", "", "", "", s.ClosingBalance, s.Currency})
+
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+// WritePDF renders the statement as an A4 document with a header, the
+// lines table and the totals.
+func WritePDF(w io.Writer, s model.Statement) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(fmt.Sprintf("Statement %d %s to %s", s.AccountID, s.From.Format(dateLayout), lastDay(s)), true)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Generated %s - page %d of {nb}", s.GeneratedAt.Format("2006-01-02 15:04 MST"), pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Account statement", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("%s (account %d, %s)", s.AccountName, s.AccountID, s.Currency)), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("Period: %s to %s", s.From.Format(dateLayout), lastDay(s)), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	widths := []float64{22, 22, 76, 35, 35}
+	header := []string{"Date", "Reference", "Description", "Amount", "Balance"}
+	aligns := []string{"L", "L", "L", "R", "R"}
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, title := range header {
+		pdf.CellFormat(widths[i], 7, title, "B", 0, aligns[i], true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	row := func(cells ...string) {
+		for i, cell := range cells {
+			pdf.CellFormat(widths[i], 6, tr(truncate(cell, 48)), "", 0, aligns[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	row(s.From.Format(dateLayout), "", "Opening balance", "", s.OpeningBalance)
+	for _, line := range s.Lines {
+		row(line.Date.Format(dateLayout), line.Reference, line.Description, line.Amount, line.Balance)
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 9)
+	for _, total := range [][2]string{
+		{"Opening balance", s.OpeningBalance},
+		{"Total in", s.TotalIn},
+		{"Total out", s.TotalOut},
+		{"Closing balance", s.ClosingBalance},
+	} {
+		pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3], 6, total[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, total[1]+" "+s.Currency, "", 1, "R", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// Filename names a download of the statement in the given format, e.g.
+// "statement-1-2026-09-01-2026-09-30.csv". It is built from the parsed period
+// only, so it never echoes what the client sent.
+func Filename(s model.Statement, format string) string {
+	return fmt.Sprintf("statement-%d-%s-%s.%s", s.AccountID, s.From.Format(dateLayout), lastDay(s), format)
+}
+
+// lastDay is the last day the statement covers; To itself is exclusive.
+func lastDay(s model.Statement) string {
+	return s.To.AddDate(0, 0, -1).Format(dateLayout)
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,62 @@
+package statement_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"payment-service/internal/model"
+	"payment-service/internal/statement"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleStatement() model.Statement {
+	transferID, counterpartyID := uint(7), uint(2)
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	return model.Statement{
+		AccountID:      1,
+		AccountName:    "Café Ñandú",
+		Currency:       "USD",
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: "100.00",
+		TotalIn:        "0.00",
+		TotalOut:       "10.00",
+		ClosingBalance: "90.00",
+		Lines: []model.StatementLine{{
+			Date:                  from.Add(36 * time.Hour),
+			TransferID:            &transferID,
+			Reference:             "TRF-7",
+			Description:           "Transfer to Bob",
+			CounterpartyAccountID: &counterpartyID,
+			CounterpartyName:      "Bob",
+			Amount:                "-10.00",
+			Balance:               "90.00",
+		}},
+		GeneratedAt: from,
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, statement.WriteCSV(&buf, sampleStatement()))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, []string{"2026-09-01", "", "", "Opening balance", "", "", "", "100.00", "USD"}, rows[1])
+	assert.Equal(t, []string{"2026-09-02T12:00:00Z", "TRF-7", "7", "Transfer to Bob", "2", "Bob", "-10.00", "90.00", "USD"}, rows[2])
+	assert.Equal(t, "2026-09-30", rows[3][0])
+	assert.Equal(t, "90.00", rows[3][7])
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, statement.WritePDF(&buf, sampleStatement()))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+}
+
+func TestFilename(t *testing.T) {
+	assert.Equal(t, "statement-1-2026-09-01-2026-09-30.csv", statement.Filename(sampleStatement(), "csv"))
+}